@@ -11,9 +11,24 @@
 package main
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+	_ "time/tzdata" // 内嵌时区数据库，避免依赖节点镜像中的 tzdata
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -25,16 +40,196 @@ type ExamPaperContract struct {
 
 // ExamPaper 试卷结构
 type ExamPaper struct {
-	PaperID     string    `json:"paper_id"`
-	ExamID      string    `json:"exam_id"`
-	Subject     string    `json:"subject"`
-	IPFSHash    string    `json:"ipfs_hash"`     // 加密文件的IPFS哈希
-	FileHash    string    `json:"file_hash"`     // 原始文件的SM3哈希
-	UnlockTime  string    `json:"unlock_time"`   // 解锁时间 (ISO8601格式)
-	Status      string    `json:"status"`        // draft, uploaded, locked, unlocked
-	UploadedBy  string    `json:"uploaded_by"`   // 上传者ID
-	CreatedAt   string    `json:"created_at"`
-	UpdatedAt   string    `json:"updated_at"`
+	PaperID    string `json:"paper_id"`
+	ExamID     string `json:"exam_id"`
+	Subject    string `json:"subject"`
+	IPFSHash   string `json:"ipfs_hash"`   // 加密文件的IPFS哈希
+	FileHash   string `json:"file_hash"`   // 原始文件的SM3哈希
+	UnlockTime string `json:"unlock_time"` // 解锁时间 (ISO8601格式)
+	Status     string `json:"status"`      // draft, uploaded, locked, unlocked
+	UploadedBy string `json:"uploaded_by"` // 上传者ID
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+
+	Replicas   []string `json:"replicas,omitempty"` // 其他节点上的副本CID
+	Department string   `json:"department,omitempty"`
+	Faculty    string   `json:"faculty,omitempty"` // 层级: faculty > department > subject
+
+	AnswerKeyCommitment string `json:"answer_key_commitment,omitempty"`  // 答案的公开承诺（哈希），即最新版本
+	AnswerKeyVersion    int    `json:"answer_key_version,omitempty"`     // 答案承诺的版本数，每次轮换递增
+	AnswerKeyUnlockTime string `json:"answer_key_unlock_time,omitempty"` // 答案发布时间，为空时与试卷解锁时间相同 (RFC3339)
+	RequiredApprovals   int    `json:"required_approvals,omitempty"`     // 解锁所需的有效审批数，0 表示无需审批
+	UploadToken         string `json:"upload_token,omitempty"`           // 委托上传时使用的令牌
+	TokenRedeemedBy     string `json:"token_redeemed_by,omitempty"`      // 兑换令牌的调用者身份
+	HashAlgo            string `json:"hash_algo,omitempty"`              // FileHash 的哈希算法，默认 SM3
+	EncryptionScheme    string `json:"encryption_scheme,omitempty"`      // 加密文件的对称加密方案，默认 SM4-CBC
+	MetaHash            string `json:"meta_hash,omitempty"`              // 不可变元数据的指纹，用于检测账本外篡改
+	ExamEndTime         string `json:"exam_end_time,omitempty"`          // 考试结束时间，之后访问关闭 (RFC3339)
+	DurationMinutes     int    `json:"duration_minutes,omitempty"`       // 考试时长，未设置 ExamEndTime 时结束时间为 UnlockTime + 时长
+
+	AllowedViewers []string `json:"allowed_viewers,omitempty"` // 可查看敏感字段的用户，为空时按角色默认处理
+	MerkleRoot     string   `json:"merkle_root,omitempty"`     // 分块哈希的 Merkle 根 (SHA-256, hex)
+	RetentionUntil string   `json:"retention_until,omitempty"` // 归档后的最短保留期限，之前不允许删除
+	ReservedBy     string   `json:"reserved_by,omitempty"`     // 预留试卷ID的用户
+
+	CoverSheet *CoverSheet `json:"cover_sheet,omitempty"` // 封面信息，解锁前也可查看
+	ExamName   string      `json:"exam_name,omitempty"`   // 考试名称（冗余存储，便于报表显示）
+
+	DuplicateFileHash bool `json:"duplicate_file_hash,omitempty"` // 存储时已有其他试卷使用相同的 FileHash
+
+	ContentType string   `json:"content_type,omitempty"` // 原始文件类型，如 application/pdf
+	Tags        []string `json:"tags,omitempty"`
+	Revision    int      `json:"revision"` // 每次写入递增，用于乐观并发控制
+
+	DetachedSignature *DetachedSignature `json:"detached_signature,omitempty"` // 外部签名机构对试卷内容的分离签名
+	CreatorMSP        string             `json:"creator_msp,omitempty"`        // 存储试卷的调用者所属组织 MSP ID（取自客户端身份）
+
+	LastProbedAt string `json:"last_probed_at,omitempty"` // 最近一次 IPFS 内容可用性探测时间
+	ProbeStatus  string `json:"probe_status,omitempty"`   // 最近一次探测结果: available, unavailable
+
+	HoldReason string `json:"hold_reason,omitempty"` // 管理员暂扣原因，非空时无论时间都不允许解锁
+
+	LastUnlockAttempt string `json:"last_unlock_attempt,omitempty"` // 最近一次失败的解锁尝试时间
+	AttemptCount      int    `json:"attempt_count,omitempty"`       // 连续失败的解锁尝试次数，解锁成功后清零
+
+	UnlockTimeZone string `json:"unlock_time_zone,omitempty"` // 按本地时间存储时的 IANA 时区，仅用于显示（UnlockTime 始终为 UTC）
+
+	RelatedPapers []string `json:"related_papers,omitempty"` // 关联试卷（补考、缓考等），关系类型见 PaperLink
+
+	RequiredApproverOrgs int      `json:"required_approver_orgs,omitempty"` // 解锁所需的不同审批组织数，0 表示不要求
+	ApproverOrgs         []string `json:"approver_orgs,omitempty"`          // 可参与审批的组织，为空表示不限制
+
+	Priority int `json:"priority,omitempty"` // 批量解锁时的优先级，越大越先处理，默认 0
+
+	Published bool `json:"published,omitempty"` // 考后公开，任何调用者都可查看完整记录
+
+	ReleaseApproved bool `json:"release_approved,omitempty"` // 管理员已批准发布
+
+	Imported bool `json:"imported,omitempty"` // 从旧系统迁移导入，CreatedAt 和初始状态取自旧系统
+
+	PreviewIPFSHash string `json:"preview_ipfs_hash,omitempty"` // 预览版（封面和首题）的IPFS哈希
+	PreviewReleased bool   `json:"preview_released,omitempty"`  // 预览已发布，试卷本身仍可处于锁定状态
+
+	Frozen        bool   `json:"frozen,omitempty"`          // 调查冻结中，除解冻外拒绝一切修改和解锁
+	FrozenCaseRef string `json:"frozen_case_ref,omitempty"` // 冻结对应的调查案件编号
+
+	GradedAt    string `json:"graded_at,omitempty"`    // 阅卷完成时间
+	GradedBy    string `json:"graded_by,omitempty"`    // 阅卷负责人
+	ResultsHash string `json:"results_hash,omitempty"` // 成绩文件的IPFS哈希
+}
+
+// DetachedSignature 分离签名，上传时不验证，需要时凭公钥通过 VerifyDetachedSignature 校验
+type DetachedSignature struct {
+	Signature   string `json:"signature"`     // base64 编码的签名
+	SignerKeyID string `json:"signer_key_id"` // 签名密钥标识（如 HSM 密钥ID）
+}
+
+// CoverSheet 试卷封面（非敏感信息）
+type CoverSheet struct {
+	Title           string `json:"title"`
+	DurationMinutes int    `json:"duration_minutes"`
+	Instructions    string `json:"instructions"`
+}
+
+// PaperOptions 存储试卷时的可选参数
+type PaperOptions struct {
+	Replicas          []string `json:"replicas"`
+	Department        string   `json:"department"`
+	Faculty           string   `json:"faculty"`
+	RequiredApprovals int      `json:"required_approvals"`
+	UploadToken       string   `json:"upload_token"`
+	HashAlgo          string   `json:"hash_algo"`
+	EncryptionScheme  string   `json:"encryption_scheme"`
+	ExamEndTime       string   `json:"exam_end_time"`
+	DurationMinutes   int      `json:"duration_minutes"`
+	MerkleRoot        string   `json:"merkle_root"`
+
+	CoverSheet *CoverSheet `json:"cover_sheet"`
+	ExamName   string      `json:"exam_name"`
+
+	ContentType string   `json:"content_type"`
+	Tags        []string `json:"tags"`
+
+	RequiredApproverOrgs int      `json:"required_approver_orgs"`
+	ApproverOrgs         []string `json:"approver_orgs"`
+
+	Priority int `json:"priority"`
+
+	PreviewIPFSHash string `json:"preview_ipfs_hash"`
+}
+
+// UploadToken 一次性委托上传令牌
+type UploadToken struct {
+	TokenID    string `json:"token_id"`
+	ExamID     string `json:"exam_id"`
+	IssuedBy   string `json:"issued_by"`
+	ValidUntil string `json:"valid_until"`
+	Consumed   bool   `json:"consumed"`
+	ConsumedBy string `json:"consumed_by,omitempty"`
+	ConsumedAt string `json:"consumed_at,omitempty"`
+}
+
+// UnlockApproval 解锁审批
+type UnlockApproval struct {
+	PaperID     string `json:"paper_id"`
+	ApproverID  string `json:"approver_id"`
+	ApprovedAt  string `json:"approved_at"`
+	ApproverMSP string `json:"approver_msp,omitempty"` // 审批人所属组织 MSP ID（取自客户端身份）
+}
+
+// ChaincodeConfig 链码全局配置
+type ChaincodeConfig struct {
+	ApprovalValiditySeconds int64  `json:"approval_validity_seconds"`  // 审批有效期，0 表示永不过期
+	RejectClockSkew         bool   `json:"reject_clock_skew"`          // UpdatedAt 回退时拒绝交易（默认钳制为原值）
+	RetentionSeconds        int64  `json:"retention_seconds"`          // 归档试卷的最短保留时长
+	StrictExamRegistry      bool   `json:"strict_exam_registry"`       // 为 true 时只能为已登记的考试存储试卷
+	RejectDuplicateFileHash bool   `json:"reject_duplicate_file_hash"` // 为 true 时拒绝重复的 FileHash，否则仅标记
+	GatewayTemplate         string `json:"gateway_template"`           // IPFS 网关 URL 模板，包含 {cid} 占位符
+	PaperIDPattern          string `json:"paper_id_pattern"`           // 试卷ID须匹配的正则，为空表示不限制
+	MinLeadTimeSeconds      int64  `json:"min_lead_time_seconds"`      // 解锁时间距交易时间的最短提前量，0 表示不限制
+	UnlockRetryBackoff      int64  `json:"unlock_retry_backoff"`       // 解锁失败后再次尝试的最短间隔（秒），0 表示不限制
+	AllowResubmission       bool   `json:"allow_resubmission"`         // 为 true 时允许学生在考试窗口内重新提交答卷
+
+	AllowedActions []string `json:"allowed_actions,omitempty"` // RecordAccess 允许的动作，为空时使用 knownAccessActions
+
+	MaxPapersPerExam int `json:"max_papers_per_exam"` // 每场考试的试卷数上限（含草稿），0 表示不限制
+
+	DedupWindowSeconds int64 `json:"dedup_window_seconds"` // RecordAccessDedup 的去重时间窗口，0 表示使用默认值
+
+	GatewayIdentities []string `json:"gateway_identities,omitempty"` // 后端网关的客户端身份ID，证书没有 role 属性，可不带令牌上传试卷
+
+	Events EventConfig `json:"events"`
+}
+
+// EventConfig 事件开关，默认全部发出
+type EventConfig struct {
+	Suppressed       []string            `json:"suppressed"`         // 全局屏蔽的事件
+	SuppressedByExam map[string][]string `json:"suppressed_by_exam"` // 按考试屏蔽的事件
+}
+
+// suppressed 判断事件是否被屏蔽
+func (e EventConfig) suppressed(name string, examID string) bool {
+	for _, n := range e.Suppressed {
+		if n == name {
+			return true
+		}
+	}
+	for _, n := range e.SuppressedByExam[examID] {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Exam 考试登记
+type Exam struct {
+	ExamID    string `json:"exam_id"`
+	Name      string `json:"name"`
+	CreatedBy string `json:"created_by"`
+	CreatedAt string `json:"created_at"`
+
+	MaxConcurrentUnlocked int `json:"max_concurrent_unlocked,omitempty"` // 同时处于 unlocked 的试卷数上限，0 表示不限制
 }
 
 // AccessLog 访问日志
@@ -42,10 +237,17 @@ type AccessLog struct {
 	LogID     string `json:"log_id"`
 	PaperID   string `json:"paper_id"`
 	UserID    string `json:"user_id"`
-	Action    string `json:"action"`    // upload, view, decrypt
+	Action    string `json:"action"` // upload, view, decrypt
 	Timestamp string `json:"timestamp"`
 	IPAddress string `json:"ip_address"`
 	Details   string `json:"details"`
+	Location  string `json:"location,omitempty"` // 监考签到地点（checkin）
+
+	KeyFingerprint string `json:"key_fingerprint,omitempty"` // 发放的解密密钥指纹（key_release），不含密钥本身
+
+	Seq      int    `json:"seq,omitempty"`       // 试卷日志链中的序号，从 1 开始；0 为启用哈希链之前的日志
+	PrevHash string `json:"prev_hash,omitempty"` // 上一条日志的哈希
+	Hash     string `json:"hash,omitempty"`      // 本条日志（含 PrevHash）的 SHA-256
 }
 
 // ===================== 初始化 =====================
@@ -56,6 +258,15 @@ func (c *ExamPaperContract) InitLedger(ctx contractapi.TransactionContextInterfa
 	return nil
 }
 
+// GetLedgerTime 获取账本时间（当前交易时间戳，UTC RFC3339），客户端据此同步倒计时，与解锁判断保持一致
+func (c *ExamPaperContract) GetLedgerTime(ctx contractapi.TransactionContextInterface) (string, error) {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	return now.Format(time.RFC3339), nil
+}
+
 // ===================== 试卷管理 =====================
 
 // StorePaper 存储试卷信息
@@ -69,249 +280,7473 @@ func (c *ExamPaperContract) StorePaper(
 	unlockTime string,
 	uploadedBy string,
 ) error {
-	// 验证参数
-	if paperID == "" || ipfsHash == "" || fileHash == "" {
-		return fmt.Errorf("paperID, ipfsHash and fileHash are required")
-	}
+	return c.StorePaperWithOptions(ctx, paperID, examID, subject, ipfsHash, fileHash, unlockTime, uploadedBy, "")
+}
 
-	// 验证IPFS哈希格式 (Qm开头，46字符)
-	if len(ipfsHash) < 46 || ipfsHash[:2] != "Qm" {
-		return fmt.Errorf("invalid IPFS hash format")
-	}
+// StorePaperWithOptions 存储试卷信息，optionsJSON 为 PaperOptions 的 JSON（可为空）
+func (c *ExamPaperContract) StorePaperWithOptions(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	examID string,
+	subject string,
+	ipfsHash string,
+	fileHash string,
+	unlockTime string,
+	uploadedBy string,
+	optionsJSON string,
+) error {
+	return storePaper(ctx, paperID, examID, subject, ipfsHash, fileHash, unlockTime, uploadedBy, optionsJSON, nil, "", nil)
+}
 
-	// 检查是否已存在
-	existing, err := ctx.GetStub().GetState(paperID)
-	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+// StorePaperSigned 存储试卷并附带外部签名机构的分离签名（base64），签名密钥不是通道成员，上传时不做验证
+// 签名对象为 signedPaperContent 的规范 JSON
+func (c *ExamPaperContract) StorePaperSigned(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	examID string,
+	subject string,
+	ipfsHash string,
+	fileHash string,
+	unlockTime string,
+	uploadedBy string,
+	optionsJSON string,
+	signature string,
+	signerKeyID string,
+) error {
+	if signature == "" || signerKeyID == "" {
+		return fmt.Errorf("signature and signerKeyID are required")
 	}
-	if existing != nil {
-		return fmt.Errorf("paper %s already exists", paperID)
+	if _, err := base64.StdEncoding.DecodeString(signature); err != nil {
+		return fmt.Errorf("signature must be base64 encoded: %v", err)
 	}
 
-	now := time.Now().UTC().Format(time.RFC3339)
+	return storePaper(ctx, paperID, examID, subject, ipfsHash, fileHash, unlockTime, uploadedBy, optionsJSON, &DetachedSignature{
+		Signature:   signature,
+		SignerKeyID: signerKeyID,
+	}, "", nil)
+}
 
-	paper := ExamPaper{
-		PaperID:    paperID,
-		ExamID:     examID,
-		Subject:    subject,
-		IPFSHash:   ipfsHash,
-		FileHash:   fileHash,
-		UnlockTime: unlockTime,
-		Status:     "locked",
-		UploadedBy: uploadedBy,
-		CreatedAt:  now,
-		UpdatedAt:  now,
-	}
+// localTimeLayout StorePaperLocal 接受的本地时间格式（不含时区偏移）
+const localTimeLayout = "2006-01-02T15:04:05"
 
-	paperJSON, err := json.Marshal(paper)
-	if err != nil {
-		return fmt.Errorf("failed to marshal paper: %v", err)
+// StorePaperLocal 按校区本地时间存储试卷：localTime（如 2024-06-01T09:00:00）按 IANA 时区（如 Asia/Shanghai）
+// 换算为 UTC 后作为解锁时间存储，时区仅保存用于显示
+func (c *ExamPaperContract) StorePaperLocal(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	examID string,
+	subject string,
+	ipfsHash string,
+	fileHash string,
+	uploadedBy string,
+	optionsJSON string,
+	localTime string,
+	ianaTZ string,
+) error {
+	// LoadLocation 对空字符串和 "Local" 返回 UTC / 节点本地时区，不能作为校区时区
+	if ianaTZ == "" || ianaTZ == "Local" {
+		return fmt.Errorf("an IANA time zone is required")
 	}
-
-	// 存储到账本
-	err = ctx.GetStub().PutState(paperID, paperJSON)
+	location, err := time.LoadLocation(ianaTZ)
 	if err != nil {
-		return fmt.Errorf("failed to put state: %v", err)
+		return fmt.Errorf("unknown time zone %s: %v", ianaTZ, err)
 	}
 
-	// 记录事件
-	err = ctx.GetStub().SetEvent("PaperStored", paperJSON)
+	local, err := time.ParseInLocation(localTimeLayout, localTime, location)
 	if err != nil {
-		return fmt.Errorf("failed to set event: %v", err)
+		return fmt.Errorf("failed to parse local time (expected %s): %v", localTimeLayout, err)
 	}
+	unlockTime := local.UTC().Format(time.RFC3339)
 
-	return nil
+	return storePaper(ctx, paperID, examID, subject, ipfsHash, fileHash, unlockTime, uploadedBy, optionsJSON, nil, ianaTZ, nil)
 }
 
-// GetPaper 获取试卷信息
-func (c *ExamPaperContract) GetPaper(
+// legacyImport 迁移导入时由调用方提供的原始创建时间和状态
+type legacyImport struct {
+	CreatedAt string
+	Status    string
+}
+
+// ImportLegacyPaper 从旧系统迁移试卷（仅管理员）：保留原始创建时间 originalCreatedAt，
+// 直接以 originalStatus（locked、unlocked 或 archived）写入而不经过正常的状态流转，并标记 Imported
+// 解锁时间可以早于当前时间，不检查最短提前量；其余校验与 StorePaperWithOptions 相同
+func (c *ExamPaperContract) ImportLegacyPaper(
 	ctx contractapi.TransactionContextInterface,
 	paperID string,
-) (*ExamPaper, error) {
-	paperJSON, err := ctx.GetStub().GetState(paperID)
+	examID string,
+	subject string,
+	ipfsHash string,
+	fileHash string,
+	unlockTime string,
+	uploadedBy string,
+	optionsJSON string,
+	originalCreatedAt string,
+	originalStatus string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	createdAt, err := parseTime(originalCreatedAt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
+		return fmt.Errorf("invalid original creation time: %v", err)
 	}
-	if paperJSON == nil {
-		return nil, fmt.Errorf("paper %s does not exist", paperID)
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if createdAt.After(now) {
+		return fmt.Errorf("original creation time %s is in the future", originalCreatedAt)
 	}
 
-	var paper ExamPaper
-	err = json.Unmarshal(paperJSON, &paper)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal paper: %v", err)
+	switch originalStatus {
+	case "locked", "unlocked", "archived":
+	default:
+		return fmt.Errorf("invalid status %s, imported papers must be locked, unlocked or archived", originalStatus)
 	}
 
-	return &paper, nil
+	return storePaper(ctx, paperID, examID, subject, ipfsHash, fileHash, unlockTime, uploadedBy, optionsJSON, nil, "", &legacyImport{
+		CreatedAt: createdAt.Format(time.RFC3339),
+		Status:    originalStatus,
+	})
 }
 
-// UpdatePaperStatus 更新试卷状态
-func (c *ExamPaperContract) UpdatePaperStatus(
+// storePaper StorePaperWithOptions、StorePaperSigned、StorePaperLocal 和 ImportLegacyPaper 的共同实现
+func storePaper(
 	ctx contractapi.TransactionContextInterface,
 	paperID string,
-	newStatus string,
+	examID string,
+	subject string,
+	ipfsHash string,
+	fileHash string,
+	unlockTime string,
+	uploadedBy string,
+	optionsJSON string,
+	signature *DetachedSignature,
+	unlockTimeZone string,
+	imported *legacyImport,
 ) error {
-	paper, err := c.GetPaper(ctx, paperID)
-	if err != nil {
+	// 验证参数
+	if paperID == "" || ipfsHash == "" || fileHash == "" {
+		return fmt.Errorf("paperID, ipfsHash and fileHash are required")
+	}
+
+	if err := validatePaperID(ctx, paperID); err != nil {
 		return err
 	}
 
-	// 验证状态转换
-	validTransitions := map[string][]string{
-		"draft":    {"locked"},
-		"locked":   {"unlocked"},
-		"unlocked": {"archived"},
+	if err := validateIPFSHash(ipfsHash); err != nil {
+		return err
 	}
 
-	allowed := false
-	for _, s := range validTransitions[paper.Status] {
-		if s == newStatus {
-			allowed = true
-			break
+	var opts PaperOptions
+	if optionsJSON != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			return fmt.Errorf("invalid options: %v", err)
 		}
 	}
 
-	if !allowed {
-		return fmt.Errorf("invalid status transition from %s to %s", paper.Status, newStatus)
+	hashAlgo := normalizeHashAlgo(opts.HashAlgo)
+	if err := validateFileHash(hashAlgo, fileHash); err != nil {
+		return err
 	}
 
-	paper.Status = newStatus
-	paper.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
-
-	paperJSON, err := json.Marshal(paper)
+	replicas, err := normalizeReplicas(ipfsHash, opts.Replicas)
 	if err != nil {
-		return fmt.Errorf("failed to marshal paper: %v", err)
+		return err
 	}
 
-	return ctx.GetStub().PutState(paperID, paperJSON)
-}
-
-// CheckUnlockTime 检查是否可以解锁
-func (c *ExamPaperContract) CheckUnlockTime(
-	ctx contractapi.TransactionContextInterface,
-	paperID string,
-) (bool, error) {
-	paper, err := c.GetPaper(ctx, paperID)
-	if err != nil {
-		return false, err
+	if opts.RequiredApprovals < 0 {
+		return fmt.Errorf("required_approvals must not be negative")
 	}
 
-	unlockTime, err := time.Parse(time.RFC3339, paper.UnlockTime)
-	if err != nil {
-		return false, fmt.Errorf("failed to parse unlock time: %v", err)
+	if opts.RequiredApproverOrgs < 0 {
+		return fmt.Errorf("required_approver_orgs must not be negative")
+	}
+	if len(opts.ApproverOrgs) > 0 && opts.RequiredApproverOrgs > len(opts.ApproverOrgs) {
+		return fmt.Errorf("required_approver_orgs %d exceeds the %d eligible approver orgs", opts.RequiredApproverOrgs, len(opts.ApproverOrgs))
 	}
 
-	now := time.Now().UTC()
-	return now.After(unlockTime), nil
-}
+	if opts.CoverSheet != nil && opts.CoverSheet.DurationMinutes < 0 {
+		return fmt.Errorf("cover sheet duration must not be negative")
+	}
 
-// UnlockPaper 解锁试卷（需要验证时间）
-func (c *ExamPaperContract) UnlockPaper(
-	ctx contractapi.TransactionContextInterface,
-	paperID string,
-	requesterID string,
-) error {
-	canUnlock, err := c.CheckUnlockTime(ctx, paperID)
-	if err != nil {
-		return err
+	if opts.PreviewIPFSHash != "" {
+		if err := validateIPFSHash(opts.PreviewIPFSHash); err != nil {
+			return fmt.Errorf("invalid preview IPFS hash: %v", err)
+		}
 	}
 
-	if !canUnlock {
-		paper, _ := c.GetPaper(ctx, paperID)
-		return fmt.Errorf("paper cannot be unlocked until %s", paper.UnlockTime)
+	if opts.MerkleRoot != "" {
+		if err := validateFileHash("SHA256", opts.MerkleRoot); err != nil {
+			return fmt.Errorf("invalid merkle root: %v", err)
+		}
 	}
 
-	// 更新状态
-	err = c.UpdatePaperStatus(ctx, paperID, "unlocked")
+	// 统一规范为 UTC 的 RFC3339，后续按字符串比较和查询的代码无需再兼容其他格式
+	parsedUnlock, err := parseTime(unlockTime)
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid unlock time: %v", err)
 	}
+	unlockTime = parsedUnlock.Format(time.RFC3339)
 
-	// 记录解锁日志
-	return c.RecordAccess(ctx, paperID, requesterID, "unlock", "", "Paper unlocked by authorized user")
-}
-
-// ===================== 访问日志 =====================
-
-// RecordAccess 记录访问日志
-func (c *ExamPaperContract) RecordAccess(
-	ctx contractapi.TransactionContextInterface,
-	paperID string,
-	userID string,
-	action string,
-	ipAddress string,
-	details string,
-) error {
-	txID := ctx.GetStub().GetTxID()
-	timestamp := time.Now().UTC().Format(time.RFC3339)
-
-	log := AccessLog{
-		LogID:     fmt.Sprintf("LOG_%s", txID[:16]),
-		PaperID:   paperID,
-		UserID:    userID,
-		Action:    action,
-		Timestamp: timestamp,
-		IPAddress: ipAddress,
-		Details:   details,
+	if imported == nil {
+		if err := checkUnlockLeadTime(ctx, unlockTime); err != nil {
+			return err
+		}
 	}
 
-	logJSON, err := json.Marshal(log)
-	if err != nil {
-		return fmt.Errorf("failed to marshal log: %v", err)
+	if opts.DurationMinutes < 0 {
+		return fmt.Errorf("duration_minutes must be positive")
+	}
+	if opts.DurationMinutes > 0 {
+		if opts.ExamEndTime != "" {
+			return fmt.Errorf("specify either exam_end_time or duration_minutes, not both")
+		}
 	}
 
-	// 使用复合键存储日志
-	compositeKey, err := ctx.GetStub().CreateCompositeKey("AccessLog", []string{paperID, log.LogID})
-	if err != nil {
-		return fmt.Errorf("failed to create composite key: %v", err)
+	examEndTime := ""
+	if opts.ExamEndTime != "" {
+		end, err := parseTime(opts.ExamEndTime)
+		if err != nil {
+			return fmt.Errorf("failed to parse exam end time: %v", err)
+		}
+		examEndTime = end.UTC().Format(time.RFC3339)
+		if err := validateTimeOrdering(unlockTime, examEndTime, ""); err != nil {
+			return err
+		}
 	}
 
-	return ctx.GetStub().PutState(compositeKey, logJSON)
-}
+	// 保持层级一致：指定系时必须同时指定学院
+	if opts.Department != "" && opts.Faculty == "" {
+		return fmt.Errorf("faculty is required when department is provided")
+	}
 
-// GetPaperAccessLogs 获取试卷的访问日志
-func (c *ExamPaperContract) GetPaperAccessLogs(
-	ctx contractapi.TransactionContextInterface,
-	paperID string,
-) ([]*AccessLog, error) {
-	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("AccessLog", []string{paperID})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get logs: %v", err)
+	if err := checkExamRegistered(ctx, examID); err != nil {
+		return err
 	}
-	defer iterator.Close()
 
-	var logs []*AccessLog
-	for iterator.HasNext() {
-		result, err := iterator.Next()
+	// 未提供考试名称时从考试登记中补全
+	examName := opts.ExamName
+	if examName == "" {
+		exam, err := getExam(ctx, examID)
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		var log AccessLog
-		err = json.Unmarshal(result.Value, &log)
-		if err != nil {
-			return nil, err
+		if exam != nil {
+			examName = exam.Name
 		}
-		logs = append(logs, &log)
 	}
 
-	return logs, nil
-}
-
+	// 检查是否已存在；已预留的草稿可以直接填充
+	var reserved *ExamPaper
+	existing, err := ctx.GetStub().GetState(paperID)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		reserved = &ExamPaper{}
+		if err := json.Unmarshal(existing, reserved); err != nil {
+			return fmt.Errorf("failed to unmarshal paper: %v", err)
+		}
+		if reserved.Status != "draft" || imported != nil {
+			return fmt.Errorf("paper %s already exists", paperID)
+		}
+		if reserved.ExamID != examID {
+			return fmt.Errorf("paper %s is reserved for exam %s", paperID, reserved.ExamID)
+		}
+	} else if err := checkExamPaperLimit(ctx, examID); err != nil {
+		return err
+	}
+
+	// 检测与其他试卷重复的文件哈希
+	duplicates, err := getPaperIDsByIndex(ctx, fileHashIndex, fileHash)
+	if err != nil {
+		return err
+	}
+	duplicate := false
+	for _, id := range duplicates {
+		if id != paperID {
+			duplicate = true
+			break
+		}
+	}
+	if duplicate {
+		config, err := getConfig(ctx)
+		if err != nil {
+			return err
+		}
+		if config.RejectDuplicateFileHash {
+			return fmt.Errorf("file hash %s is already used by paper(s) %v", fileHash, duplicates)
+		}
+	}
+
+	// 委托上传：提供令牌时校验并兑换，记录兑换者；不带令牌时调用者须为可上传角色或已配置的后端网关
+	var token *UploadToken
+	if opts.UploadToken != "" {
+		token, err = redeemUploadToken(ctx, opts.UploadToken, examID)
+		if err != nil {
+			return err
+		}
+	} else if err := requireUploader(ctx); err != nil {
+		return err
+	}
+
+	creatorMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	now := txTime.Format(time.RFC3339)
+
+	paper := ExamPaper{
+		PaperID:    paperID,
+		ExamID:     examID,
+		Subject:    subject,
+		IPFSHash:   ipfsHash,
+		FileHash:   fileHash,
+		UnlockTime: unlockTime,
+		Status:     "locked",
+		UploadedBy: uploadedBy,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Replicas:   replicas,
+		Department: opts.Department,
+		Faculty:    opts.Faculty,
+
+		RequiredApprovals: opts.RequiredApprovals,
+		HashAlgo:          hashAlgo,
+		EncryptionScheme:  normalizeEncryptionScheme(opts.EncryptionScheme),
+		ExamEndTime:       examEndTime,
+		DurationMinutes:   opts.DurationMinutes,
+		MerkleRoot:        strings.ToLower(opts.MerkleRoot),
+		CoverSheet:        opts.CoverSheet,
+		ExamName:          examName,
+		DuplicateFileHash: duplicate,
+		ContentType:       opts.ContentType,
+		Tags:              opts.Tags,
+		DetachedSignature: signature,
+		UnlockTimeZone:    unlockTimeZone,
+
+		RequiredApproverOrgs: opts.RequiredApproverOrgs,
+		ApproverOrgs:         opts.ApproverOrgs,
+		Priority:             opts.Priority,
+		CreatorMSP:           creatorMSP,
+		PreviewIPFSHash:      opts.PreviewIPFSHash,
+	}
+
+	if token != nil {
+		paper.UploadToken = token.TokenID
+		paper.TokenRedeemedBy = token.ConsumedBy
+	}
+
+	// 填充预留草稿：保留预留信息，状态 draft -> locked
+	if reserved != nil {
+		paper.CreatedAt = reserved.CreatedAt
+		paper.UpdatedAt = reserved.UpdatedAt
+		paper.ReservedBy = reserved.ReservedBy
+		paper.Revision = reserved.Revision
+	}
+
+	// 迁移导入：使用旧系统的创建时间和状态
+	if imported != nil {
+		paper.CreatedAt = imported.CreatedAt
+		paper.Status = imported.Status
+		paper.Imported = true
+	}
+
+	paper.MetaHash, err = computeMetaHash(&paper)
+	if err != nil {
+		return err
+	}
+
+	// 存储到账本
+	err = savePaper(ctx, &paper)
+	if err != nil {
+		return fmt.Errorf("failed to put state: %v", err)
+	}
+
+	err = putPaperIndexes(ctx, &paper)
+	if err != nil {
+		return err
+	}
+
+	// 记录事件
+	return emitEvent(ctx, "PaperStored", paper.ExamID, paper)
+}
+
+// ReservePaperID 预留试卷ID：创建内容为空的 draft 占位记录，之后通过 StorePaper 填充
+func (c *ExamPaperContract) ReservePaperID(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	examID string,
+	requesterID string,
+) error {
+	if err := requireRole(ctx, uploaderRoles...); err != nil {
+		return err
+	}
+	if paperID == "" || examID == "" {
+		return fmt.Errorf("paperID and examID are required")
+	}
+	if err := validatePaperID(ctx, paperID); err != nil {
+		return err
+	}
+	if err := checkExamRegistered(ctx, examID); err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(paperID)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("paper %s already exists", paperID)
+	}
+	if err := checkExamPaperLimit(ctx, examID); err != nil {
+		return err
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	paper := ExamPaper{
+		PaperID:    paperID,
+		ExamID:     examID,
+		Status:     "draft",
+		CreatedAt:  txTime.Format(time.RFC3339),
+		ReservedBy: requesterID,
+	}
+
+	err = savePaper(ctx, &paper)
+	if err != nil {
+		return err
+	}
+
+	return recordAccess(ctx, paperID, requesterID, "reserve", "", fmt.Sprintf("Paper ID reserved for exam %s", examID))
+}
+
+// FinalizeDraft 填充预留草稿的内容并直接转为 locked，执行与 StorePaper 相同的全部校验
+// 预留时不含科目，由 subject 提供；上传者仍记为预留试卷ID的用户，requesterID 记入访问日志
+func (c *ExamPaperContract) FinalizeDraft(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	subject string,
+	ipfsHash string,
+	fileHash string,
+	unlockTime string,
+	requesterID string,
+) error {
+	if subject == "" {
+		return fmt.Errorf("subject is required")
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if paper.Status != "draft" {
+		return fmt.Errorf("paper %s is %s, only draft papers can be finalized", paperID, paper.Status)
+	}
+
+	uploadedBy := paper.ReservedBy
+	if uploadedBy == "" {
+		uploadedBy = requesterID
+	}
+
+	err = storePaper(ctx, paperID, paper.ExamID, subject, ipfsHash, fileHash, unlockTime, uploadedBy, "", nil, "", nil)
+	if err != nil {
+		return err
+	}
+
+	return recordAccess(ctx, paperID, requesterID, "upload", "", fmt.Sprintf("Draft finalized, reserved by %s", uploadedBy))
+}
+
+// GetPaper 获取试卷信息（纯查询，evaluate 调用，不记录访问日志）
+// 试卷设置了查看名单时，名单外的非管理员调用者看到的是隐去敏感字段的版本
+func (c *ExamPaperContract) GetPaper(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*ExamPaper, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	return redactForCaller(ctx, paper)
+}
+
+// GetCoverSheet 获取试卷封面，不受锁定状态和查看名单限制
+func (c *ExamPaperContract) GetCoverSheet(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*CoverSheet, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	if paper.CoverSheet == nil {
+		return nil, fmt.Errorf("paper %s has no cover sheet", paperID)
+	}
+
+	return paper.CoverSheet, nil
+}
+
+// StudentPaperView 学生端所需的最小试卷信息，不含内部字段
+type StudentPaperView struct {
+	PaperID          string `json:"paper_id"`
+	Subject          string `json:"subject"`
+	ExamName         string `json:"exam_name"`
+	IPFSHash         string `json:"ipfs_hash"`
+	FileHash         string `json:"file_hash"`
+	ContentType      string `json:"content_type"`
+	EncryptionScheme string `json:"encryption_scheme"` // 学生端据此选择解密算法
+	Status           string `json:"status"`
+}
+
+// GetStudentView 获取学生视图：仅在试卷已解锁且考试窗口未关闭时返回，查看名单限制照常生效
+func (c *ExamPaperContract) GetStudentView(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*StudentPaperView, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	if paper.Status != "unlocked" {
+		return nil, fmt.Errorf("paper %s is %s, the student view is only available once unlocked", paperID, paper.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state, err := examWindowState(paper, now)
+	if err != nil {
+		return nil, err
+	}
+	if state == windowClosed {
+		return nil, fmt.Errorf("access to paper %s closed at %s", paperID, examEndTimeString(paper))
+	}
+
+	visible, err := redactForCaller(ctx, paper)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StudentPaperView{
+		PaperID:          visible.PaperID,
+		Subject:          visible.Subject,
+		ExamName:         visible.ExamName,
+		IPFSHash:         visible.IPFSHash,
+		FileHash:         visible.FileHash,
+		ContentType:      visible.ContentType,
+		EncryptionScheme: normalizeEncryptionScheme(visible.EncryptionScheme), // 早于该字段存储的试卷按默认方案处理
+		Status:           visible.Status,
+	}, nil
+}
+
+// PaperWithURL 附带网关地址的试卷信息
+type PaperWithURL struct {
+	Paper    *ExamPaper `json:"paper"`
+	CID      string     `json:"cid"`
+	FetchURL string     `json:"fetch_url"` // 未配置网关模板时为空，客户端直接使用 CID
+}
+
+// GetPaperWithURL 获取试卷信息及按链上网关模板生成的下载地址
+func (c *ExamPaperContract) GetPaperWithURL(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*PaperWithURL, error) {
+	paper, err := c.GetPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PaperWithURL{Paper: paper, CID: paper.IPFSHash}
+	if config.GatewayTemplate != "" && paper.IPFSHash != "" {
+		result.FetchURL = strings.ReplaceAll(config.GatewayTemplate, "{cid}", paper.IPFSHash)
+	}
+
+	return result, nil
+}
+
+// ViewPaper 查看试卷并记录 view 访问日志
+// 注意：这是一个写账本的交易（需 submit 调用），不需要留痕时请使用 GetPaper
+func (c *ExamPaperContract) ViewPaper(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	userID string,
+	ipAddress string,
+) (*ExamPaper, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state, err := examWindowState(paper, now)
+	if err != nil {
+		return nil, err
+	}
+	if state == windowClosed {
+		return nil, fmt.Errorf("access to paper %s closed at %s", paperID, examEndTimeString(paper))
+	}
+
+	err = recordAccess(ctx, paperID, userID, "view", ipAddress, "Paper metadata viewed")
+	if err != nil {
+		return nil, err
+	}
+
+	return redactForCaller(ctx, paper)
+}
+
+// UpdatePaperStatus 更新试卷状态
+// locked -> unlocked 与 UnlockPaper 检查相同的前置条件（角色、暂扣、冻结、考试窗口、审批和并发上限）
+func (c *ExamPaperContract) UpdatePaperStatus(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	newStatus string,
+) error {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+
+	if paper.Status == "locked" && newStatus == "unlocked" && !paper.Published {
+		requesterID, err := callerUserID(ctx)
+		if err != nil {
+			return err
+		}
+		return unlockPaper(ctx, paper, requesterID)
+	}
+
+	// 验证状态转换
+	if err := checkStatusChange(paper, newStatus, false); err != nil {
+		return err
+	}
+
+	paper.Status = newStatus
+
+	// 归档时按配置计算保留期限
+	if newStatus == "archived" {
+		config, err := getConfig(ctx)
+		if err != nil {
+			return err
+		}
+		now, err := getTxTime(ctx)
+		if err != nil {
+			return err
+		}
+		paper.RetentionUntil = now.Add(time.Duration(config.RetentionSeconds) * time.Second).Format(time.RFC3339)
+	}
+
+	return savePaper(ctx, paper)
+}
+
+// DeletePaper 删除试卷（仅管理员）
+// 只允许删除草稿，或已归档且超过保留期限的试卷；访问日志保留以供审计
+func (c *ExamPaperContract) DeletePaper(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	requesterID string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if err := checkNotFrozen(paper); err != nil {
+		return err
+	}
+
+	switch paper.Status {
+	case "draft":
+	case "archived":
+		now, err := getTxTime(ctx)
+		if err != nil {
+			return err
+		}
+		expired, err := retentionExpired(paper, now)
+		if err != nil {
+			return err
+		}
+		if !expired {
+			return fmt.Errorf("paper %s must be retained until %s", paperID, paper.RetentionUntil)
+		}
+	default:
+		return fmt.Errorf("paper %s is %s, only draft or archived papers can be deleted", paperID, paper.Status)
+	}
+
+	err = ctx.GetStub().DelState(paperID)
+	if err != nil {
+		return fmt.Errorf("failed to delete paper %s: %v", paperID, err)
+	}
+
+	err = deletePaperIndexes(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	// 去重记录只对仍存在的试卷有意义，删除试卷时一并清除（访问日志保留）
+	if err := pruneAccessDedupMarkers(ctx, paperID, time.Time{}, ""); err != nil {
+		return err
+	}
+
+	return recordAccess(ctx, paperID, requesterID, "delete", "", "Paper deleted")
+}
+
+// PurgeSkip 未清除的试卷及原因
+type PurgeSkip struct {
+	PaperID string `json:"paper_id"`
+	Reason  string `json:"reason"`
+}
+
+// PurgeReport 清除考试的结果
+type PurgeReport struct {
+	ExamID  string       `json:"exam_id"`
+	Purged  []string     `json:"purged"`
+	Skipped []*PurgeSkip `json:"skipped"`
+}
+
+// PurgeExam 清除考试中已归档且超过保留期限的试卷，连同其访问日志、审批和索引（仅管理员）
+// 不符合条件的试卷跳过并在报告中说明原因，不中止整个交易
+func (c *ExamPaperContract) PurgeExam(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+	requesterID string,
+) (*PurgeReport, error) {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return nil, err
+	}
+	if examID == "" {
+		return nil, fmt.Errorf("examID is required")
+	}
+
+	papers, err := queryPapers(ctx, map[string]interface{}{
+		"exam_id":  examID,
+		"paper_id": map[string]interface{}{"$exists": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(papers, func(i, j int) bool { return papers[i].PaperID < papers[j].PaperID })
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PurgeReport{ExamID: examID, Purged: []string{}, Skipped: []*PurgeSkip{}}
+	for _, paper := range papers {
+		if err := checkNotFrozen(paper); err != nil {
+			report.Skipped = append(report.Skipped, &PurgeSkip{PaperID: paper.PaperID, Reason: err.Error()})
+			continue
+		}
+		if paper.Status != "archived" {
+			report.Skipped = append(report.Skipped, &PurgeSkip{
+				PaperID: paper.PaperID,
+				Reason:  fmt.Sprintf("paper is %s, only archived papers can be purged", paper.Status),
+			})
+			continue
+		}
+
+		expired, err := retentionExpired(paper, now)
+		if err != nil {
+			report.Skipped = append(report.Skipped, &PurgeSkip{PaperID: paper.PaperID, Reason: err.Error()})
+			continue
+		}
+		if !expired {
+			report.Skipped = append(report.Skipped, &PurgeSkip{
+				PaperID: paper.PaperID,
+				Reason:  fmt.Sprintf("paper must be retained until %s", paper.RetentionUntil),
+			})
+			continue
+		}
+
+		if err := ctx.GetStub().DelState(paper.PaperID); err != nil {
+			return nil, fmt.Errorf("failed to delete paper %s: %v", paper.PaperID, err)
+		}
+		if err := deletePaperIndexes(ctx, paper); err != nil {
+			return nil, err
+		}
+		if err := deletePaperScopedKeys(ctx, paper.PaperID); err != nil {
+			return nil, err
+		}
+		report.Purged = append(report.Purged, paper.PaperID)
+	}
+
+	err = emitEvent(ctx, "ExamPurged", examID, map[string]interface{}{
+		"exam_id":      examID,
+		"purged":       len(report.Purged),
+		"skipped":      len(report.Skipped),
+		"requester_id": requesterID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// PublishPaper 考后公开已归档的试卷，之后任何调用者都能通过 GetPaper 查看完整记录（仅管理员）
+func (c *ExamPaperContract) PublishPaper(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if paper.Status != "archived" {
+		return fmt.Errorf("paper %s is %s, only archived papers can be published", paperID, paper.Status)
+	}
+	if paper.Published {
+		return fmt.Errorf("paper %s is already published", paperID)
+	}
+
+	paper.Published = true
+
+	err = savePaper(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	adminID, err := callerUserID(ctx)
+	if err != nil {
+		return err
+	}
+	return recordAccess(ctx, paperID, adminID, "publish", "", "Paper published")
+}
+
+// ReleasePreview 发布试卷预览（封面和首题），试卷本身保持锁定
+func (c *ExamPaperContract) ReleasePreview(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	requesterID string,
+) error {
+	if err := requireRole(ctx, "admin", "coe"); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if paper.Status != "locked" {
+		return fmt.Errorf("paper %s is %s, previews can only be released for locked papers", paperID, paper.Status)
+	}
+	if paper.PreviewIPFSHash == "" {
+		return fmt.Errorf("paper %s has no preview", paperID)
+	}
+	if err := validateIPFSHash(paper.PreviewIPFSHash); err != nil {
+		return fmt.Errorf("invalid preview IPFS hash: %v", err)
+	}
+	if paper.PreviewReleased {
+		return fmt.Errorf("preview of paper %s is already released", paperID)
+	}
+
+	paper.PreviewReleased = true
+
+	err = savePaper(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	return recordAccess(ctx, paperID, requesterID, "preview_release", "", "Paper preview released")
+}
+
+// GetPaperPreview 获取已发布预览的IPFS哈希，与试卷本身是否解锁无关
+func (c *ExamPaperContract) GetPaperPreview(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (string, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return "", err
+	}
+	if !paper.PreviewReleased {
+		return "", fmt.Errorf("preview of paper %s has not been released", paperID)
+	}
+	return paper.PreviewIPFSHash, nil
+}
+
+// GetPublishedPapers 分页获取已公开的试卷（公开题库），bookmark 为空表示从第一页开始
+func (c *ExamPaperContract) GetPublishedPapers(
+	ctx contractapi.TransactionContextInterface,
+	pageSize int32,
+	bookmark string,
+) (*PaginatedResult, error) {
+	if err := validateBookmark(bookmark); err != nil {
+		return nil, err
+	}
+
+	query := `{"selector":{"published":true}}`
+
+	iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %v", err)
+	}
+	defer iterator.Close()
+
+	papers := []*ExamPaper{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var paper ExamPaper
+		if err := json.Unmarshal(result.Value, &paper); err != nil {
+			continue
+		}
+		papers = append(papers, &paper)
+	}
+
+	visible, err := redactPapersForCaller(ctx, papers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedResult{
+		Papers:      visible,
+		RecordCount: metadata.FetchedRecordsCount,
+		Bookmark:    metadata.Bookmark,
+	}, nil
+}
+
+// PatchPaper 按白名单部分更新试卷的可变字段，patchJSON 为 JSON 对象（键为 JSON 字段名）
+// expectedRevision 与当前 Revision 不一致时拒绝，防止覆盖并发修改
+func (c *ExamPaperContract) PatchPaper(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	patchJSON string,
+	expectedRevision int,
+	requesterID string,
+) error {
+	if err := requireRole(ctx, uploaderRoles...); err != nil {
+		return err
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(patchJSON), &patch); err != nil {
+		return fmt.Errorf("invalid patch: %v", err)
+	}
+	if len(patch) == 0 {
+		return fmt.Errorf("patch is empty")
+	}
+
+	fields := make([]string, 0, len(patch))
+	for field := range patch {
+		if immutablePaperFields[field] {
+			return fmt.Errorf("field %s is immutable", field)
+		}
+		if !patchablePaperFields[field] {
+			return fmt.Errorf("field %s cannot be patched", field)
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if paper.Revision != expectedRevision {
+		return fmt.Errorf("revision mismatch: expected %d, current %d", expectedRevision, paper.Revision)
+	}
+
+	// 在当前记录的 JSON 上覆盖补丁字段，再解析回结构体
+	current, err := json.Marshal(paper)
+	if err != nil {
+		return fmt.Errorf("failed to marshal paper: %v", err)
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(current, &merged); err != nil {
+		return fmt.Errorf("failed to unmarshal paper: %v", err)
+	}
+	for field, value := range patch {
+		merged[field] = value
+	}
+	mergedJSON, err := json.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("failed to marshal patched paper: %v", err)
+	}
+
+	var patched ExamPaper
+	if err := json.Unmarshal(mergedJSON, &patched); err != nil {
+		return fmt.Errorf("invalid patch value: %v", err)
+	}
+
+	if patched.Department != "" && patched.Faculty == "" {
+		return fmt.Errorf("faculty is required when department is provided")
+	}
+	if patched.CoverSheet != nil && patched.CoverSheet.DurationMinutes < 0 {
+		return fmt.Errorf("cover sheet duration must not be negative")
+	}
+
+	err = savePaper(ctx, &patched)
+	if err != nil {
+		return err
+	}
+
+	return recordAccess(ctx, paperID, requesterID, "patch", "", fmt.Sprintf("Patched fields: %s", strings.Join(fields, ", ")))
+}
+
+// RenamePaper 修改试卷ID：迁移试卷记录及其所有按试卷ID组织的复合键，删除旧记录
+// 关联试卷一端的关联记录和 RelatedPapers 同时改为新ID
+func (c *ExamPaperContract) RenamePaper(
+	ctx contractapi.TransactionContextInterface,
+	oldID string,
+	newID string,
+	requesterID string,
+) error {
+	if err := requireRole(ctx, uploaderRoles...); err != nil {
+		return err
+	}
+	if newID == "" {
+		return fmt.Errorf("newID is required")
+	}
+	if newID == oldID {
+		return fmt.Errorf("newID must differ from oldID")
+	}
+	if err := validatePaperID(ctx, newID); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, oldID)
+	if err != nil {
+		return err
+	}
+	if err := checkNotFrozen(paper); err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(newID)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("paper %s already exists", newID)
+	}
+
+	// 迁移日志等复合键（同一交易内写入，整体原子提交）
+	err = movePaperScopedKeys(ctx, oldID, newID)
+	if err != nil {
+		return err
+	}
+
+	err = repointPaperLinks(ctx, paper, newID)
+	if err != nil {
+		return err
+	}
+
+	err = deletePaperIndexes(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	paper.PaperID = newID
+	paper.MetaHash, err = computeMetaHash(paper)
+	if err != nil {
+		return err
+	}
+
+	err = savePaper(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(oldID)
+	if err != nil {
+		return fmt.Errorf("failed to delete paper %s: %v", oldID, err)
+	}
+
+	err = putPaperIndexes(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	return recordAccess(ctx, newID, requesterID, "rename", "", fmt.Sprintf("Paper renamed from %s", oldID))
+}
+
+// Relock 重新锁定已解锁的试卷并设置新的解锁时间（考试中出现安全事件时使用，仅管理员）
+func (c *ExamPaperContract) Relock(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	newUnlockTime string,
+	requesterID string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkStatusChange(paper, "locked", true); err != nil {
+		return err
+	}
+
+	unlockTime, err := parseTime(newUnlockTime)
+	if err != nil {
+		return fmt.Errorf("failed to parse unlock time: %v", err)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !unlockTime.After(now) {
+		return fmt.Errorf("new unlock time must be in the future")
+	}
+
+	paper.Status = "locked"
+	paper.UnlockTime = unlockTime.UTC().Format(time.RFC3339)
+	if err := validatePaperTimeOrdering(paper); err != nil {
+		return err
+	}
+
+	err = savePaper(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	err = recordAccess(ctx, paperID, requesterID, "relock", "", fmt.Sprintf("Paper relocked until %s", paper.UnlockTime))
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "PaperRelocked", paper.ExamID, map[string]string{
+		"paper_id":     paper.PaperID,
+		"exam_id":      paper.ExamID,
+		"unlock_time":  paper.UnlockTime,
+		"requester_id": requesterID,
+	})
+}
+
+// UpdateUnlockTime 修改已锁定试卷的解锁时间（需满足最短提前量，且早于考试结束时间）
+func (c *ExamPaperContract) UpdateUnlockTime(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	newUnlockTime string,
+	requesterID string,
+) error {
+	if err := requireRole(ctx, "admin", "coe"); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if paper.Status != "locked" {
+		return fmt.Errorf("paper %s is %s, only locked papers can be rescheduled", paperID, paper.Status)
+	}
+
+	unlockTime, err := parseTime(newUnlockTime)
+	if err != nil {
+		return fmt.Errorf("failed to parse unlock time: %v", err)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !unlockTime.After(now) {
+		return fmt.Errorf("new unlock time must be in the future")
+	}
+	if err := checkUnlockLeadTime(ctx, newUnlockTime); err != nil {
+		return err
+	}
+
+	previous := paper.UnlockTime
+	paper.UnlockTime = unlockTime.UTC().Format(time.RFC3339)
+	if err := validatePaperTimeOrdering(paper); err != nil {
+		return err
+	}
+
+	err = savePaper(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	return recordAccess(ctx, paperID, requesterID, "reschedule", "", fmt.Sprintf("Unlock time changed from %s to %s", previous, paper.UnlockTime))
+}
+
+// ExamWindow 试卷的考试窗口
+type ExamWindow struct {
+	PaperID         string `json:"paper_id"`
+	Start           string `json:"start"`            // 即解锁时间 (UTC RFC3339)
+	End             string `json:"end"`              // 为空表示窗口不关闭
+	DurationMinutes int    `json:"duration_minutes"` // 窗口时长，没有结束时间时为 0
+}
+
+// GetExamWindow 获取试卷的考试窗口，结束时间由 ExamEndTime 或 UnlockTime + DurationMinutes 得出
+func (c *ExamPaperContract) GetExamWindow(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*ExamWindow, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := examWindowBounds(paper)
+	if err != nil {
+		return nil, err
+	}
+
+	window := &ExamWindow{PaperID: paperID, Start: start.UTC().Format(time.RFC3339)}
+	if !end.IsZero() {
+		window.End = end.UTC().Format(time.RFC3339)
+		window.DurationMinutes = int(end.Sub(start) / time.Minute)
+	}
+	return window, nil
+}
+
+// CheckUnlockTime 检查是否可以解锁（当前处于 [UnlockTime, ExamEndTime) 窗口内）
+func (c *ExamPaperContract) CheckUnlockTime(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (bool, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return false, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	state, err := examWindowState(paper, now)
+	if err != nil {
+		return false, err
+	}
+	return state == windowOpen, nil
+}
+
+// UnlockCountdown 解锁倒计时
+type UnlockCountdown struct {
+	PaperID          string `json:"paper_id"`
+	UnlockTime       string `json:"unlock_time"`       // 规范化的解锁时间 (UTC RFC3339)
+	LedgerTime       string `json:"ledger_time"`       // 计算所依据的交易时间
+	SecondsRemaining int64  `json:"seconds_remaining"` // 距解锁的秒数，已可解锁时为负数或 0
+	Unlockable       bool   `json:"unlockable"`        // 已到解锁时间
+}
+
+// GetTimeUntilUnlock 以交易时间为准计算距解锁的剩余时间，供客户端显示统一的倒计时
+func (c *ExamPaperContract) GetTimeUntilUnlock(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*UnlockCountdown, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	unlockTime, err := parseTime(paper.UnlockTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse unlock time: %v", err)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := int64(unlockTime.Sub(now) / time.Second)
+	return &UnlockCountdown{
+		PaperID:          paperID,
+		UnlockTime:       unlockTime.UTC().Format(time.RFC3339),
+		LedgerTime:       now.Format(time.RFC3339),
+		SecondsRemaining: remaining,
+		Unlockable:       !now.Before(unlockTime),
+	}, nil
+}
+
+// 派生状态
+const (
+	effectiveDraft            = "draft"
+	effectiveOnHold           = "on_hold"
+	effectiveLockedWaiting    = "locked_waiting"    // 已锁定，未到解锁时间
+	effectiveLockedUnlockable = "locked_unlockable" // 已锁定，已到解锁时间、尚未解锁
+	effectiveUnlocked         = "unlocked"
+	effectiveSealed           = "sealed" // 考试窗口已关闭，拒绝解锁和查看
+	effectiveArchived         = "archived"
+)
+
+// EffectiveStatus 结合存储状态、暂扣和交易时间推导的试卷状态
+type EffectiveStatus struct {
+	PaperID         string `json:"paper_id"`
+	StoredStatus    string `json:"stored_status"`
+	EffectiveStatus string `json:"effective_status"`
+	EvaluatedAt     string `json:"evaluated_at"` // 推导所依据的交易时间
+}
+
+// GetEffectiveStatus 以交易时间为准推导试卷的有效状态（纯查询），供各客户端统一显示
+// 判定顺序：draft、archived 直接返回；暂扣优先于时间；其余按考试窗口区分等待、可解锁、已关闭
+func (c *ExamPaperContract) GetEffectiveStatus(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*EffectiveStatus, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &EffectiveStatus{
+		PaperID:      paperID,
+		StoredStatus: paper.Status,
+		EvaluatedAt:  now.Format(time.RFC3339),
+	}
+
+	switch {
+	case paper.Status == "draft":
+		result.EffectiveStatus = effectiveDraft
+	case paper.Status == "archived":
+		result.EffectiveStatus = effectiveArchived
+	case paper.HoldReason != "":
+		result.EffectiveStatus = effectiveOnHold
+	default:
+		state, err := examWindowState(paper, now)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case state == windowClosed:
+			result.EffectiveStatus = effectiveSealed
+		case paper.Status == "unlocked":
+			result.EffectiveStatus = effectiveUnlocked
+		case state == windowPending:
+			result.EffectiveStatus = effectiveLockedWaiting
+		default:
+			result.EffectiveStatus = effectiveLockedUnlockable
+		}
+	}
+
+	return result, nil
+}
+
+// UnlockPaper 解锁试卷（需要验证时间）
+func (c *ExamPaperContract) UnlockPaper(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	requesterID string,
+) error {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+
+	return unlockPaper(ctx, paper, requesterID)
+}
+
+// unlockPaper 检查全部解锁前置条件后解锁试卷并记录日志，供 UnlockPaper 和 UpdatePaperStatus 共用
+func unlockPaper(ctx contractapi.TransactionContextInterface, paper *ExamPaper, requesterID string) error {
+	reason, err := unlockBlockReason(ctx, paper)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("%s", reason)
+	}
+
+	// 更新状态
+	err = markUnlocked(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	// 记录解锁日志
+	return recordAccess(ctx, paper.PaperID, requesterID, "unlock", "", "Paper unlocked by authorized user")
+}
+
+// AttemptUnlock 供调度器使用的解锁：条件不满足时不返回错误，而是记录失败次数和时间并返回原因
+// （失败的交易不会提交写入，UnlockPaper 无法记录失败尝试）；距上次失败不足退避间隔时直接拒绝且不计数
+func (c *ExamPaperContract) AttemptUnlock(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	requesterID string,
+) (*UnlockCheck, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	wait, err := unlockRetryWait(ctx, paper)
+	if err != nil {
+		return nil, err
+	}
+	if wait != "" {
+		return &UnlockCheck{Allowed: false, Reason: wait}, nil
+	}
+
+	reason, err := unlockBlockReason(ctx, paper)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason == "" {
+		if err := markUnlocked(ctx, paper); err != nil {
+			return nil, err
+		}
+		if err := recordAccess(ctx, paperID, requesterID, "unlock", "", "Paper unlocked by authorized user"); err != nil {
+			return nil, err
+		}
+		return &UnlockCheck{Allowed: true}, nil
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	paper.LastUnlockAttempt = now.Format(time.RFC3339)
+	paper.AttemptCount++
+
+	if err := savePaper(ctx, paper); err != nil {
+		return nil, err
+	}
+	if err := recordAccess(ctx, paperID, requesterID, "unlock_failed", "", reason); err != nil {
+		return nil, err
+	}
+
+	return &UnlockCheck{Allowed: false, Reason: reason}, nil
+}
+
+// UnlockCheck 解锁前置条件评估结果
+type UnlockCheck struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"` // 不允许解锁时的原因
+}
+
+// CanUnlock 评估调用者当前能否解锁试卷（纯查询，与 UnlockPaper 共用前置条件检查）
+func (c *ExamPaperContract) CanUnlock(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*UnlockCheck, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, err := unlockBlockReason(ctx, paper)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UnlockCheck{Allowed: reason == "", Reason: reason}, nil
+}
+
+// GetUnlockableNow 获取当前可以解锁的试卷：已锁定、处于考试窗口内、未被暂扣且审批已满足
+// 按 Priority 从高到低排序，优先级相同时按解锁时间从早到晚，再按试卷ID排序
+func (c *ExamPaperContract) GetUnlockableNow(
+	ctx contractapi.TransactionContextInterface,
+) ([]*ExamPaper, error) {
+	papers, err := unlockablePapers(ctx, map[string]interface{}{"status": "locked"})
+	if err != nil {
+		return nil, err
+	}
+	return redactPapersForCaller(ctx, papers)
+}
+
+// UnlockExamPapers 按 GetUnlockableNow 的顺序解锁考试中当前可以解锁的全部试卷，返回已解锁的试卷ID
+func (c *ExamPaperContract) UnlockExamPapers(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+) ([]string, error) {
+	if err := requireRole(ctx, unlockRoles...); err != nil {
+		return nil, err
+	}
+
+	papers, err := unlockablePapers(ctx, map[string]interface{}{"status": "locked", "exam_id": examID})
+	if err != nil {
+		return nil, err
+	}
+
+	requesterID, err := callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一交易内读不到本批写入，按批前的剩余名额截断
+	remaining, limited, err := examUnlockCapacity(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+	if limited && len(papers) > remaining {
+		if remaining < 0 {
+			remaining = 0
+		}
+		papers = papers[:remaining]
+	}
+
+	unlocked := []string{}
+	for _, paper := range papers {
+		if err := markUnlocked(ctx, paper); err != nil {
+			return nil, err
+		}
+		if err := recordAccess(ctx, paper.PaperID, requesterID, "unlock", "", "Paper unlocked in exam batch"); err != nil {
+			return nil, err
+		}
+		unlocked = append(unlocked, paper.PaperID)
+	}
+
+	return unlocked, nil
+}
+
+// unlockablePapers 查询满足 selector 且当前可以解锁的试卷，按优先级排序
+func unlockablePapers(ctx contractapi.TransactionContextInterface, selector map[string]interface{}) ([]*ExamPaper, error) {
+	papers, err := queryPapers(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unlockable := []*ExamPaper{}
+	for _, paper := range papers {
+		if paper.HoldReason != "" || paper.Frozen {
+			continue
+		}
+		state, err := examWindowState(paper, now)
+		if err != nil || state != windowOpen {
+			continue
+		}
+		shortfall, err := approvalShortfall(ctx, paper)
+		if err != nil {
+			return nil, err
+		}
+		if shortfall != "" {
+			continue
+		}
+		unlockable = append(unlockable, paper)
+	}
+
+	// UnlockTime 均为 RFC3339，可解析时已通过窗口检查，按时间比较
+	sort.Slice(unlockable, func(i, j int) bool {
+		a, b := unlockable[i], unlockable[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		ta, _ := parseTime(a.UnlockTime)
+		tb, _ := parseTime(b.UnlockTime)
+		if !ta.Equal(tb) {
+			return ta.Before(tb)
+		}
+		return a.PaperID < b.PaperID
+	})
+
+	return unlockable, nil
+}
+
+// TriggerUnlockCheck 到达解锁时间后自动解锁试卷并发出 AutoUnlocked 事件
+// 供调度器周期调用；试卷非 locked 状态或未到解锁时间时不做任何修改，返回是否发生了解锁
+func (c *ExamPaperContract) TriggerUnlockCheck(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (bool, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return false, err
+	}
+
+	// 调度器不代表具体用户，只检查与调用者无关的解锁条件
+	reason, err := unlockGateReason(ctx, paper)
+	if err != nil {
+		return false, err
+	}
+	if reason != "" {
+		return false, nil
+	}
+
+	err = markUnlocked(ctx, paper)
+	if err != nil {
+		return false, err
+	}
+
+	err = recordAccess(ctx, paperID, "system", "unlock", "", "Paper unlocked automatically after unlock time")
+	if err != nil {
+		return false, err
+	}
+
+	err = emitEvent(ctx, "AutoUnlocked", paper.ExamID, map[string]string{
+		"paper_id": paper.PaperID,
+		"exam_id":  paper.ExamID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// PlaceHold 暂扣试卷：在解除前无论是否到达解锁时间都不允许解锁（仅管理员）
+func (c *ExamPaperContract) PlaceHold(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	reason string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("hold reason is required")
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if paper.Status != "draft" && paper.Status != "locked" {
+		return fmt.Errorf("paper %s is %s, only draft or locked papers can be held", paperID, paper.Status)
+	}
+
+	paper.HoldReason = reason
+
+	err = savePaper(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	adminID, err := callerUserID(ctx)
+	if err != nil {
+		return err
+	}
+	return recordAccess(ctx, paperID, adminID, "hold", "", fmt.Sprintf("Hold placed: %s", reason))
+}
+
+// ReleaseHold 解除试卷的暂扣（仅管理员）
+func (c *ExamPaperContract) ReleaseHold(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if paper.HoldReason == "" {
+		return fmt.Errorf("paper %s is not on hold", paperID)
+	}
+
+	previous := paper.HoldReason
+	paper.HoldReason = ""
+
+	err = savePaper(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	adminID, err := callerUserID(ctx)
+	if err != nil {
+		return err
+	}
+	return recordAccess(ctx, paperID, adminID, "release_hold", "", fmt.Sprintf("Hold released: %s", previous))
+}
+
+// Freeze 因泄题调查冻结试卷：解冻前拒绝一切修改、删除和解锁，读取不受影响（仅管理员）
+func (c *ExamPaperContract) Freeze(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	caseRef string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if caseRef == "" {
+		return fmt.Errorf("caseRef is required")
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if err := checkNotFrozen(paper); err != nil {
+		return err
+	}
+
+	paper.Frozen = true
+	paper.FrozenCaseRef = caseRef
+
+	err = savePaper(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	adminID, err := callerUserID(ctx)
+	if err != nil {
+		return err
+	}
+	return recordAccess(ctx, paperID, adminID, "freeze", "", fmt.Sprintf("Frozen for investigation (case %s)", caseRef))
+}
+
+// Unfreeze 解除试卷的调查冻结（仅管理员）
+func (c *ExamPaperContract) Unfreeze(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if !paper.Frozen {
+		return fmt.Errorf("paper %s is not frozen", paperID)
+	}
+
+	caseRef := paper.FrozenCaseRef
+	paper.Frozen = false
+	paper.FrozenCaseRef = ""
+
+	err = savePaper(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	adminID, err := callerUserID(ctx)
+	if err != nil {
+		return err
+	}
+	return recordAccess(ctx, paperID, adminID, "unfreeze", "", fmt.Sprintf("Investigation freeze lifted (case %s)", caseRef))
+}
+
+// ===================== 解锁审批 =====================
+
+// ApproveUnlock 审批试卷解锁，同一审批人重复审批会刷新审批时间；冻结中的试卷不接受审批
+func (c *ExamPaperContract) ApproveUnlock(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	approverID string,
+) error {
+	if err := requireRole(ctx, "admin", "coe"); err != nil {
+		return err
+	}
+	if approverID == "" {
+		return fmt.Errorf("approverID is required")
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if err := checkNotFrozen(paper); err != nil {
+		return err
+	}
+	if paper.Status != "locked" {
+		return fmt.Errorf("paper %s is %s, only locked papers can be approved", paperID, paper.Status)
+	}
+
+	approverMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	if len(paper.ApproverOrgs) > 0 && !containsString(paper.ApproverOrgs, approverMSP) {
+		return fmt.Errorf("org %s is not an eligible approver for paper %s", approverMSP, paperID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	approval := UnlockApproval{
+		PaperID:     paperID,
+		ApproverID:  approverID,
+		ApprovedAt:  now.Format(time.RFC3339),
+		ApproverMSP: approverMSP,
+	}
+
+	approvalJSON, err := json.Marshal(approval)
+	if err != nil {
+		return fmt.Errorf("failed to marshal approval: %v", err)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("UnlockApproval", []string{paperID, approverID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(key, approvalJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put state: %v", err)
+	}
+
+	return recordAccess(ctx, paperID, approverID, "approve", "", "Unlock approved")
+}
+
+// GetUnlockApprovals 获取试卷的全部解锁审批（含已过期的）
+func (c *ExamPaperContract) GetUnlockApprovals(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]*UnlockApproval, error) {
+	return getUnlockApprovals(ctx, paperID)
+}
+
+// maxReleaseBatchSize 批量发布审批的最大试卷数
+const maxReleaseBatchSize = 100
+
+// ReleaseApprovalResult 批量发布审批中单份试卷的结果
+type ReleaseApprovalResult struct {
+	PaperID  string `json:"paper_id"`
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"` // 跳过的原因
+}
+
+// ApproveReleaseBatch 批量批准已锁定试卷的发布，paperIDsJSON 为试卷ID数组（仅管理员）
+// 不适合审批的试卷跳过并在结果中说明原因，不中止整批；每份批准的试卷记录日志，最后发出一个汇总事件
+func (c *ExamPaperContract) ApproveReleaseBatch(
+	ctx contractapi.TransactionContextInterface,
+	paperIDsJSON string,
+	adminID string,
+) ([]*ReleaseApprovalResult, error) {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return nil, err
+	}
+
+	var paperIDs []string
+	if err := json.Unmarshal([]byte(paperIDsJSON), &paperIDs); err != nil {
+		return nil, fmt.Errorf("invalid paper IDs: %v", err)
+	}
+	if len(paperIDs) > maxReleaseBatchSize {
+		return nil, fmt.Errorf("batch size %d exceeds maximum of %d", len(paperIDs), maxReleaseBatchSize)
+	}
+
+	results := make([]*ReleaseApprovalResult, 0, len(paperIDs))
+	seen := make(map[string]bool, len(paperIDs))
+	approved := 0
+	for _, paperID := range paperIDs {
+		result := &ReleaseApprovalResult{PaperID: paperID}
+		results = append(results, result)
+
+		// 同一交易内读不到自己的写入，重复的ID只处理一次
+		if seen[paperID] {
+			result.Reason = "duplicate paper ID in batch"
+			continue
+		}
+		seen[paperID] = true
+
+		paperJSON, err := ctx.GetStub().GetState(paperID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if paperJSON == nil {
+			result.Reason = "paper does not exist"
+			continue
+		}
+		var paper ExamPaper
+		if err := json.Unmarshal(paperJSON, &paper); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal paper: %v", err)
+		}
+
+		if err := checkNotFrozen(&paper); err != nil {
+			result.Reason = err.Error()
+			continue
+		}
+		if paper.Status != "locked" {
+			result.Reason = fmt.Sprintf("paper is %s, only locked papers can be approved for release", paper.Status)
+			continue
+		}
+		if paper.ReleaseApproved {
+			result.Reason = "release already approved"
+			continue
+		}
+
+		paper.ReleaseApproved = true
+		if err := savePaper(ctx, &paper); err != nil {
+			return nil, err
+		}
+		if err := recordAccess(ctx, paperID, adminID, "release_approve", "", "Release approved in batch"); err != nil {
+			return nil, err
+		}
+		result.Approved = true
+		approved++
+	}
+
+	err := emitEvent(ctx, "ReleaseBatchApproved", "", map[string]interface{}{
+		"admin_id": adminID,
+		"approved": approved,
+		"skipped":  len(results) - approved,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// CleanupExpiredApprovals 删除已过期的解锁审批，返回删除数量
+func (c *ExamPaperContract) CleanupExpiredApprovals(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (int, error) {
+	approvals, err := getUnlockApprovals(ctx, paperID)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, approval := range approvals {
+		valid, err := isApprovalValid(ctx, approval)
+		if err != nil {
+			return removed, err
+		}
+		if valid {
+			continue
+		}
+
+		key, err := ctx.GetStub().CreateCompositeKey("UnlockApproval", []string{paperID, approval.ApproverID})
+		if err != nil {
+			return removed, fmt.Errorf("failed to create composite key: %v", err)
+		}
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return removed, fmt.Errorf("failed to delete approval: %v", err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// ===================== 委托上传 =====================
+
+// IssueUploadToken 为考试签发一次性委托上传令牌，返回令牌ID
+func (c *ExamPaperContract) IssueUploadToken(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+	validUntilRFC3339 string,
+) (string, error) {
+	if err := requireRole(ctx, uploaderRoles...); err != nil {
+		return "", err
+	}
+	if examID == "" {
+		return "", fmt.Errorf("examID is required")
+	}
+
+	validUntil, err := parseTime(validUntilRFC3339)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse valid until time: %v", err)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !validUntil.After(now) {
+		return "", fmt.Errorf("token validity must end in the future")
+	}
+
+	issuer, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read client identity: %v", err)
+	}
+
+	token := UploadToken{
+		TokenID:    fmt.Sprintf("UT_%s", ctx.GetStub().GetTxID()),
+		ExamID:     examID,
+		IssuedBy:   issuer,
+		ValidUntil: validUntil.UTC().Format(time.RFC3339),
+	}
+
+	err = putUploadToken(ctx, &token)
+	if err != nil {
+		return "", err
+	}
+
+	return token.TokenID, nil
+}
+
+// GetUploadToken 查询委托上传令牌
+func (c *ExamPaperContract) GetUploadToken(
+	ctx contractapi.TransactionContextInterface,
+	tokenID string,
+) (*UploadToken, error) {
+	return getUploadToken(ctx, tokenID)
+}
+
+// ===================== 事件订阅登记 =====================
+
+// EventConsumer 下游事件消费者登记
+type EventConsumer struct {
+	ConsumerID   string   `json:"consumer_id"`
+	EventNames   []string `json:"event_names"`
+	RegisteredAt string   `json:"registered_at"`
+}
+
+// EventCoverage 事件覆盖情况
+type EventCoverage struct {
+	Covered  []string `json:"covered"`  // 已有消费者的事件
+	Orphaned []string `json:"orphaned"` // 链码发出但没有消费者登记的事件
+	Unknown  []string `json:"unknown"`  // 消费者登记了但链码从不发出的事件
+}
+
+// emittedEvents 链码会发出的全部事件
+var emittedEvents = []string{"PaperStored", "AutoUnlocked", "PaperRelocked", "ExamPurged", "ReleaseBatchApproved"}
+
+// RegisterEventConsumer 登记（或更新）消费者关心的事件，eventNamesJSON 为事件名数组（仅管理员）
+func (c *ExamPaperContract) RegisterEventConsumer(
+	ctx contractapi.TransactionContextInterface,
+	consumerID string,
+	eventNamesJSON string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if consumerID == "" {
+		return fmt.Errorf("consumerID is required")
+	}
+
+	var eventNames []string
+	if err := json.Unmarshal([]byte(eventNamesJSON), &eventNames); err != nil {
+		return fmt.Errorf("invalid event names: %v", err)
+	}
+	if len(eventNames) == 0 {
+		return fmt.Errorf("at least one event name is required")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	consumer := EventConsumer{
+		ConsumerID:   consumerID,
+		EventNames:   eventNames,
+		RegisteredAt: now.Format(time.RFC3339),
+	}
+
+	consumerJSON, err := json.Marshal(consumer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal consumer: %v", err)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("EventConsumer", []string{consumerID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	return ctx.GetStub().PutState(key, consumerJSON)
+}
+
+// GetEventConsumers 获取全部事件消费者登记
+func (c *ExamPaperContract) GetEventConsumers(
+	ctx contractapi.TransactionContextInterface,
+) ([]*EventConsumer, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("EventConsumer", []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consumers: %v", err)
+	}
+	defer iterator.Close()
+
+	consumers := []*EventConsumer{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var consumer EventConsumer
+		err = json.Unmarshal(result.Value, &consumer)
+		if err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, &consumer)
+	}
+
+	return consumers, nil
+}
+
+// CheckEventCoverage 检查每个发出的事件是否至少有一个消费者，并列出无人消费和无从发出的事件
+func (c *ExamPaperContract) CheckEventCoverage(
+	ctx contractapi.TransactionContextInterface,
+) (*EventCoverage, error) {
+	consumers, err := c.GetEventConsumers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	consumed := make(map[string]bool)
+	for _, consumer := range consumers {
+		for _, name := range consumer.EventNames {
+			consumed[name] = true
+		}
+	}
+
+	coverage := &EventCoverage{Covered: []string{}, Orphaned: []string{}, Unknown: []string{}}
+	emitted := make(map[string]bool, len(emittedEvents))
+	for _, name := range emittedEvents {
+		emitted[name] = true
+		if consumed[name] {
+			coverage.Covered = append(coverage.Covered, name)
+		} else {
+			coverage.Orphaned = append(coverage.Orphaned, name)
+		}
+	}
+	for name := range consumed {
+		if !emitted[name] {
+			coverage.Unknown = append(coverage.Unknown, name)
+		}
+	}
+	sort.Strings(coverage.Unknown)
+
+	return coverage, nil
+}
+
+// replayedPaper 重放事件的负载：当前试卷状态加 replay 标记
+type replayedPaper struct {
+	*ExamPaper
+	Replay bool `json:"replay"`
+}
+
+// ReplayPaperEvent 以试卷当前状态重新发出 PaperStored 事件（replay 为 true），供消费者停机后对账；不修改账本（仅管理员）
+func (c *ExamPaperContract) ReplayPaperEvent(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "PaperStored", paper.ExamID, replayedPaper{ExamPaper: paper, Replay: true})
+}
+
+// ===================== 配置 =====================
+
+// GetConfig 获取链码配置
+func (c *ExamPaperContract) GetConfig(ctx contractapi.TransactionContextInterface) (*ChaincodeConfig, error) {
+	return getConfig(ctx)
+}
+
+// SetApprovalValidity 设置解锁审批的有效期（秒），0 表示永不过期
+func (c *ExamPaperContract) SetApprovalValidity(
+	ctx contractapi.TransactionContextInterface,
+	seconds int64,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if seconds < 0 {
+		return fmt.Errorf("approval validity must not be negative")
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.ApprovalValiditySeconds = seconds
+
+	return putConfig(ctx, config)
+}
+
+// SetRetentionPeriod 设置归档试卷的最短保留时长（秒），仅对之后归档的试卷生效
+func (c *ExamPaperContract) SetRetentionPeriod(
+	ctx contractapi.TransactionContextInterface,
+	seconds int64,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if seconds < 0 {
+		return fmt.Errorf("retention period must not be negative")
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.RetentionSeconds = seconds
+
+	return putConfig(ctx, config)
+}
+
+// SetEventConfig 设置事件开关，configJSON 为 EventConfig 的 JSON（仅管理员）
+func (c *ExamPaperContract) SetEventConfig(
+	ctx contractapi.TransactionContextInterface,
+	configJSON string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	var events EventConfig
+	if err := json.Unmarshal([]byte(configJSON), &events); err != nil {
+		return fmt.Errorf("invalid event config: %v", err)
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.Events = events
+
+	return putConfig(ctx, config)
+}
+
+// SetRejectDuplicateFileHash 设置存储时遇到重复文件哈希的处理方式：true 拒绝，false 仅标记
+func (c *ExamPaperContract) SetRejectDuplicateFileHash(
+	ctx contractapi.TransactionContextInterface,
+	reject bool,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.RejectDuplicateFileHash = reject
+
+	return putConfig(ctx, config)
+}
+
+// SetAllowResubmission 设置是否允许学生重新提交答卷（默认拒绝重复提交）
+func (c *ExamPaperContract) SetAllowResubmission(
+	ctx contractapi.TransactionContextInterface,
+	allow bool,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.AllowResubmission = allow
+
+	return putConfig(ctx, config)
+}
+
+// SetDedupWindow 设置 RecordAccessDedup 的去重时间窗口（秒），0 表示使用默认值（仅管理员）
+func (c *ExamPaperContract) SetDedupWindow(
+	ctx contractapi.TransactionContextInterface,
+	seconds int64,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if seconds < 0 {
+		return fmt.Errorf("dedup window must not be negative")
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.DedupWindowSeconds = seconds
+
+	return putConfig(ctx, config)
+}
+
+// SetMaxPapersPerExam 设置每场考试的试卷数上限，0 表示不限制（仅管理员）
+func (c *ExamPaperContract) SetMaxPapersPerExam(
+	ctx contractapi.TransactionContextInterface,
+	maxPapers int,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if maxPapers < 0 {
+		return fmt.Errorf("maxPapers must not be negative")
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.MaxPapersPerExam = maxPapers
+
+	return putConfig(ctx, config)
+}
+
+// SetAllowedActions 设置 RecordAccess 允许的动作，actionsJSON 为动作名数组，空数组恢复为内置列表（仅管理员）
+func (c *ExamPaperContract) SetAllowedActions(
+	ctx contractapi.TransactionContextInterface,
+	actionsJSON string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	var actions []string
+	if err := json.Unmarshal([]byte(actionsJSON), &actions); err != nil {
+		return fmt.Errorf("invalid actions: %v", err)
+	}
+
+	seen := make(map[string]bool, len(actions))
+	allowed := []string{}
+	for _, action := range actions {
+		if action == "" {
+			return fmt.Errorf("actions must not contain empty values")
+		}
+		if !seen[action] {
+			seen[action] = true
+			allowed = append(allowed, action)
+		}
+	}
+	sort.Strings(allowed)
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.AllowedActions = allowed
+
+	return putConfig(ctx, config)
+}
+
+// SetGatewayTemplate 设置 IPFS 网关 URL 模板（如 https://gateway.example.com/ipfs/{cid}），为空表示不使用
+func (c *ExamPaperContract) SetGatewayTemplate(
+	ctx contractapi.TransactionContextInterface,
+	template string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if template != "" && !strings.Contains(template, "{cid}") {
+		return fmt.Errorf("gateway template must contain {cid}")
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.GatewayTemplate = template
+
+	return putConfig(ctx, config)
+}
+
+// SetMinLeadTime 设置解锁时间的最短提前量（秒），0 表示不限制
+func (c *ExamPaperContract) SetMinLeadTime(
+	ctx contractapi.TransactionContextInterface,
+	seconds int64,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if seconds < 0 {
+		return fmt.Errorf("minimum lead time must not be negative")
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.MinLeadTimeSeconds = seconds
+
+	return putConfig(ctx, config)
+}
+
+// SetUnlockRetryBackoff 设置解锁失败后再次尝试的最短间隔（秒），0 表示不限制
+func (c *ExamPaperContract) SetUnlockRetryBackoff(
+	ctx contractapi.TransactionContextInterface,
+	seconds int64,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if seconds < 0 {
+		return fmt.Errorf("unlock retry backoff must not be negative")
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.UnlockRetryBackoff = seconds
+
+	return putConfig(ctx, config)
+}
+
+// SetGatewayIdentities 设置后端网关的客户端身份ID（如 x509::CN=gateway,...），identitiesJSON 为ID数组，
+// 这些身份不带 role 属性也可以上传试卷，空数组表示不设网关（仅管理员）
+func (c *ExamPaperContract) SetGatewayIdentities(
+	ctx contractapi.TransactionContextInterface,
+	identitiesJSON string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	var identities []string
+	if err := json.Unmarshal([]byte(identitiesJSON), &identities); err != nil {
+		return fmt.Errorf("invalid gateway identities: %v", err)
+	}
+
+	seen := make(map[string]bool, len(identities))
+	gateways := []string{}
+	for _, id := range identities {
+		if id == "" {
+			return fmt.Errorf("gateway identities must not contain empty values")
+		}
+		if !seen[id] {
+			seen[id] = true
+			gateways = append(gateways, id)
+		}
+	}
+	sort.Strings(gateways)
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.GatewayIdentities = gateways
+
+	return putConfig(ctx, config)
+}
+
+// SetPaperIDPattern 设置试卷ID须匹配的正则（如 ^EXAM\d{4}-[A-Z]+-\d{3}$），为空表示不限制
+func (c *ExamPaperContract) SetPaperIDPattern(
+	ctx contractapi.TransactionContextInterface,
+	pattern string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if pattern != "" {
+		if _, err := compilePaperIDPattern(pattern); err != nil {
+			return fmt.Errorf("invalid paperID pattern: %v", err)
+		}
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.PaperIDPattern = pattern
+
+	return putConfig(ctx, config)
+}
+
+// SetStrictExamRegistry 设置是否要求试卷所属考试已登记
+func (c *ExamPaperContract) SetStrictExamRegistry(
+	ctx contractapi.TransactionContextInterface,
+	strict bool,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.StrictExamRegistry = strict
+
+	return putConfig(ctx, config)
+}
+
+// SetRejectClockSkew 设置 UpdatedAt 回退时的处理方式：true 拒绝交易，false 钳制为原值
+func (c *ExamPaperContract) SetRejectClockSkew(
+	ctx contractapi.TransactionContextInterface,
+	reject bool,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	config.RejectClockSkew = reject
+
+	return putConfig(ctx, config)
+}
+
+// ===================== 访问日志 =====================
+
+// RecordAccess 记录访问日志
+// 试卷必须存在，避免拼写错误产生孤立日志；delete/emergency 动作允许试卷已被删除
+// 动作必须在允许列表中（SetAllowedActions 配置，未配置时为 knownAccessActions）
+func (c *ExamPaperContract) RecordAccess(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	userID string,
+	action string,
+	ipAddress string,
+	details string,
+) error {
+	return c.RecordAccessDedup(ctx, paperID, userID, action, ipAddress, details, "")
+}
+
+// defaultDedupWindowSeconds 未配置 DedupWindowSeconds 时的去重时间窗口
+const defaultDedupWindowSeconds = 60
+
+// accessDedupKeyType 访问日志去重记录的复合键类型，键为 (paperID, userID, action, dedupKey)，值为最近一次写入时间
+const accessDedupKeyType = "AccessDedup"
+
+// RecordAccessDedup 与 RecordAccess 相同，但 dedupKey 非空时在去重窗口内对相同的
+// (paperID, userID, action, dedupKey) 只写一次日志，重复调用直接成功返回，避免客户端重试导致重复计数
+func (c *ExamPaperContract) RecordAccessDedup(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	userID string,
+	action string,
+	ipAddress string,
+	details string,
+	dedupKey string,
+) error {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	allowed := config.AllowedActions
+	if len(allowed) == 0 {
+		allowed = knownAccessActions
+	}
+	if !containsString(allowed, action) {
+		return fmt.Errorf("unknown action %q, valid actions: %s", action, strings.Join(allowed, ", "))
+	}
+
+	if !orphanLogActions[action] {
+		paperJSON, err := ctx.GetStub().GetState(paperID)
+		if err != nil {
+			return fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if paperJSON == nil {
+			return fmt.Errorf("paper %s does not exist", paperID)
+		}
+	}
+
+	if dedupKey != "" {
+		key, err := ctx.GetStub().CreateCompositeKey(accessDedupKeyType, []string{paperID, userID, action, dedupKey})
+		if err != nil {
+			return fmt.Errorf("failed to create composite key: %v", err)
+		}
+		lastJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read from world state: %v", err)
+		}
+		now, err := getTxTime(ctx)
+		if err != nil {
+			return err
+		}
+
+		window := config.DedupWindowSeconds
+		if window == 0 {
+			window = defaultDedupWindowSeconds
+		}
+		cutoff := now.Add(-time.Duration(window) * time.Second)
+		if lastJSON != nil {
+			last, err := parseTime(string(lastJSON))
+			if err == nil && last.After(cutoff) {
+				return nil
+			}
+		}
+
+		// 顺带清理该试卷下已过去重窗口的记录，避免去重键无限增长
+		if err := pruneAccessDedupMarkers(ctx, paperID, cutoff, key); err != nil {
+			return err
+		}
+		if err := ctx.GetStub().PutState(key, []byte(now.Format(time.RFC3339))); err != nil {
+			return fmt.Errorf("failed to put dedup key: %v", err)
+		}
+	}
+
+	return recordAccess(ctx, paperID, userID, action, ipAddress, details)
+}
+
+// pruneAccessDedupMarkers 删除试卷下写入时间不晚于 cutoff 的去重记录（无法解析的记录一并删除），
+// keepKey 为本次将要覆盖的键，跳过不删；cutoff 为零值时删除该试卷的全部去重记录
+func pruneAccessDedupMarkers(ctx contractapi.TransactionContextInterface, paperID string, cutoff time.Time, keepKey string) error {
+	stub := ctx.GetStub()
+	iterator, err := stub.GetStateByPartialCompositeKey(accessDedupKeyType, []string{paperID})
+	if err != nil {
+		return fmt.Errorf("failed to get %s entries: %v", accessDedupKeyType, err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return err
+		}
+		if result.Key == keepKey {
+			continue
+		}
+		if !cutoff.IsZero() {
+			last, err := parseTime(string(result.Value))
+			if err == nil && last.After(cutoff) {
+				continue
+			}
+		}
+		if err := stub.DelState(result.Key); err != nil {
+			return fmt.Errorf("failed to delete dedup key: %v", err)
+		}
+	}
+	return nil
+}
+
+// ForceRecordAccess 不检查试卷是否存在直接记录日志，用于确需孤立日志的少数情况（仅管理员）
+func (c *ExamPaperContract) ForceRecordAccess(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	userID string,
+	action string,
+	ipAddress string,
+	details string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	return recordAccess(ctx, paperID, userID, action, ipAddress, details)
+}
+
+// GetPaperAccessLogs 获取试卷的访问日志
+func (c *ExamPaperContract) GetPaperAccessLogs(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]*AccessLog, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("AccessLog", []string{paperID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %v", err)
+	}
+	defer iterator.Close()
+
+	var logs []*AccessLog
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var log AccessLog
+		err = json.Unmarshal(result.Value, &log)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, &log)
+	}
+
+	return logs, nil
+}
+
+// CheckInPaper 监考员登记已解锁试卷的分发签到（实体考场或线上会场）
+func (c *ExamPaperContract) CheckInPaper(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	proctorID string,
+	location string,
+) error {
+	if proctorID == "" || location == "" {
+		return fmt.Errorf("proctorID and location are required")
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if paper.Status != "unlocked" {
+		return fmt.Errorf("paper %s is %s, only unlocked papers can be checked in", paperID, paper.Status)
+	}
+
+	return putAccessLog(ctx, &AccessLog{
+		PaperID:  paperID,
+		UserID:   proctorID,
+		Action:   "checkin",
+		Details:  "Paper checked in by proctor",
+		Location: location,
+	})
+}
+
+// GetCheckIns 获取试卷的签到日志，按时间排序
+func (c *ExamPaperContract) GetCheckIns(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]*AccessLog, error) {
+	logs, err := c.GetPaperAccessLogs(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	checkIns := []*AccessLog{}
+	for _, log := range logs {
+		if log.Action == "checkin" {
+			checkIns = append(checkIns, log)
+		}
+	}
+	sort.SliceStable(checkIns, func(i, j int) bool { return checkIns[i].Timestamp < checkIns[j].Timestamp })
+
+	return checkIns, nil
+}
+
+// RecordKeyRelease 记录向 recipientID 发放试卷解密密钥（只记录指纹），试卷须已解锁且已到解锁时间
+func (c *ExamPaperContract) RecordKeyRelease(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	recipientID string,
+	keyFingerprint string,
+) error {
+	if err := requireRole(ctx, unlockRoles...); err != nil {
+		return err
+	}
+	if recipientID == "" || keyFingerprint == "" {
+		return fmt.Errorf("recipientID and keyFingerprint are required")
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if paper.Status != "unlocked" {
+		return fmt.Errorf("paper %s is %s, keys can only be released for unlocked papers", paperID, paper.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	unlockTime, err := parseTime(paper.UnlockTime)
+	if err != nil {
+		return fmt.Errorf("failed to parse unlock time: %v", err)
+	}
+	if now.Before(unlockTime) {
+		return fmt.Errorf("keys for paper %s cannot be released until %s", paperID, paper.UnlockTime)
+	}
+
+	return putAccessLog(ctx, &AccessLog{
+		PaperID:        paperID,
+		UserID:         recipientID,
+		Action:         "key_release",
+		Details:        "Decryption key released",
+		KeyFingerprint: keyFingerprint,
+	})
+}
+
+// GetKeyReleases 获取试卷的密钥发放记录，按时间排序
+func (c *ExamPaperContract) GetKeyReleases(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]*AccessLog, error) {
+	logs, err := c.GetPaperAccessLogs(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := []*AccessLog{}
+	for _, log := range logs {
+		if log.Action == "key_release" {
+			releases = append(releases, log)
+		}
+	}
+	sort.SliceStable(releases, func(i, j int) bool { return releases[i].Timestamp < releases[j].Timestamp })
+
+	return releases, nil
+}
+
+// GetRecentAccessLogs 获取试卷最近的 limit 条访问日志，按时间从新到旧排序（日志键按 LogID 排列，不是时间顺序）
+// 时间相同时按日志链序号、日志ID倒序；日志不足 limit 条时全部返回
+func (c *ExamPaperContract) GetRecentAccessLogs(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	limit int,
+) ([]*AccessLog, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	logs, err := c.GetPaperAccessLogs(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(logs, func(i, j int) bool {
+		a, b := logs[i], logs[j]
+		if a.Timestamp != b.Timestamp {
+			return a.Timestamp > b.Timestamp
+		}
+		if a.Seq != b.Seq {
+			return a.Seq > b.Seq
+		}
+		return a.LogID > b.LogID
+	})
+
+	if len(logs) > limit {
+		logs = logs[:limit]
+	}
+	if logs == nil {
+		logs = []*AccessLog{}
+	}
+	return logs, nil
+}
+
+// PaginatedLogs 访问日志分页结果（与 PaginatedResult 对应）
+type PaginatedLogs struct {
+	Logs        []*AccessLog `json:"logs"`
+	RecordCount int32        `json:"record_count"`
+	Bookmark    string       `json:"bookmark"`
+	HasMore     bool         `json:"has_more"`
+}
+
+// GetPaperAccessLogsPaginated 分页获取试卷的访问日志（按日志ID顺序），bookmark 为空表示从第一页开始
+func (c *ExamPaperContract) GetPaperAccessLogsPaginated(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	pageSize int32,
+	bookmark string,
+) (*PaginatedLogs, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be positive")
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination("AccessLog", []string{paperID}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %v", err)
+	}
+	defer iterator.Close()
+
+	logs := []*AccessLog{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var log AccessLog
+		err = json.Unmarshal(result.Value, &log)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, &log)
+	}
+
+	// 整页返回时用书签探测是否还有后续记录，恰好读完最后一整页时 HasMore 为 false；
+	// 客户端用书签继续读取直到 HasMore 为 false
+	hasMore := false
+	if metadata.FetchedRecordsCount == pageSize && metadata.Bookmark != "" {
+		probe, _, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination("AccessLog", []string{paperID}, 1, metadata.Bookmark)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get logs: %v", err)
+		}
+		hasMore = probe.HasNext()
+		probe.Close()
+	}
+
+	return &PaginatedLogs{
+		Logs:        logs,
+		RecordCount: metadata.FetchedRecordsCount,
+		Bookmark:    metadata.Bookmark,
+		HasMore:     hasMore,
+	}, nil
+}
+
+// examLogBookmark 跨试卷日志分页的位置：上一页最后一条日志所在的试卷及日志ID
+type examLogBookmark struct {
+	PaperID string `json:"paper_id"`
+	LogID   string `json:"log_id"`
+}
+
+// GetExamAccessLogs 分页获取考试下所有试卷的访问日志
+// 按试卷ID、日志ID顺序遍历，书签记录续读位置；每页内按时间排序
+func (c *ExamPaperContract) GetExamAccessLogs(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+	pageSize int32,
+	bookmark string,
+) (*PaginatedLogs, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be positive")
+	}
+
+	var position examLogBookmark
+	if bookmark != "" {
+		raw, err := base64.StdEncoding.DecodeString(bookmark)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bookmark")
+		}
+		if err := json.Unmarshal(raw, &position); err != nil {
+			return nil, fmt.Errorf("invalid bookmark")
+		}
+	}
+
+	papers, err := getExamPapers(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(papers, func(i, j int) bool { return papers[i].PaperID < papers[j].PaperID })
+
+	page := &PaginatedLogs{Logs: []*AccessLog{}}
+	var last examLogBookmark
+	hasMore := false
+
+	for _, paper := range papers {
+		if paper.PaperID < position.PaperID {
+			continue
+		}
+
+		logs, err := c.GetPaperAccessLogs(ctx, paper.PaperID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, log := range logs {
+			if paper.PaperID == position.PaperID && log.LogID <= position.LogID {
+				continue
+			}
+			if int32(len(page.Logs)) == pageSize {
+				hasMore = true
+				break
+			}
+			page.Logs = append(page.Logs, log)
+			last = examLogBookmark{PaperID: paper.PaperID, LogID: log.LogID}
+		}
+
+		if hasMore {
+			break
+		}
+	}
+
+	page.RecordCount = int32(len(page.Logs))
+	page.HasMore = hasMore
+	if hasMore {
+		raw, err := json.Marshal(last)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal bookmark: %v", err)
+		}
+		page.Bookmark = base64.StdEncoding.EncodeToString(raw)
+	}
+
+	sort.SliceStable(page.Logs, func(i, j int) bool { return page.Logs[i].Timestamp < page.Logs[j].Timestamp })
+
+	return page, nil
+}
+
+// LogChainResult 单份试卷访问日志哈希链的校验结果
+type LogChainResult struct {
+	PaperID    string `json:"paper_id"`
+	Length     int    `json:"length"`      // 链上日志条数
+	Unchained  int    `json:"unchained"`   // 启用哈希链之前的日志条数（不参与校验）
+	Intact     bool   `json:"intact"`      // 哈希链完整
+	BreakIndex int    `json:"break_index"` // 第一处断裂的序号，完整时为 -1
+	Reason     string `json:"reason,omitempty"`
+}
+
+// VerifyPaperLogChain 校验试卷访问日志的哈希链：序号连续、PrevHash 衔接、哈希可重算且与链头一致
+func (c *ExamPaperContract) VerifyPaperLogChain(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*LogChainResult, error) {
+	logs, err := c.GetPaperAccessLogs(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	return verifyLogChain(ctx, paperID, logs)
+}
+
+// LogCountResult 试卷日志计数校验结果
+type LogCountResult struct {
+	PaperID    string `json:"paper_id"`
+	Expected   int    `json:"expected"`  // 链头记录的日志计数
+	Actual     int    `json:"actual"`    // 账本中实际存在的链上日志条数
+	Unchained  int    `json:"unchained"` // 启用计数之前的日志条数（不参与比较）
+	Consistent bool   `json:"consistent"`
+}
+
+// VerifyLogCount 比较链头计数与实际日志键数，检测被带外删除的日志
+// 计数保存在链头而不是试卷记录上：写日志的交易通常也会写试卷，同一交易内读不到刚写入的试卷
+func (c *ExamPaperContract) VerifyLogCount(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*LogCountResult, error) {
+	if _, err := getPaper(ctx, paperID); err != nil {
+		return nil, err
+	}
+
+	head, err := getLogChainHead(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := c.GetPaperAccessLogs(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LogCountResult{PaperID: paperID, Expected: head.Seq}
+	for _, log := range logs {
+		if log.Seq == 0 {
+			result.Unchained++
+		} else {
+			result.Actual++
+		}
+	}
+	result.Consistent = result.Actual == result.Expected
+
+	return result, nil
+}
+
+// ExamLogChainReport 考试访问日志哈希链的批量校验结果
+type ExamLogChainReport struct {
+	ExamID  string            `json:"exam_id"`
+	Checked int               `json:"checked"` // 有日志并参与校验的试卷数
+	Broken  []*LogChainResult `json:"broken"`
+}
+
+// VerifyExamLogChains 校验考试中每份试卷的访问日志哈希链，列出断裂的试卷及断裂位置，没有日志的试卷跳过
+func (c *ExamPaperContract) VerifyExamLogChains(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+) (*ExamLogChainReport, error) {
+	papers, err := queryPapers(ctx, map[string]interface{}{
+		"exam_id":  examID,
+		"paper_id": map[string]interface{}{"$exists": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(papers, func(i, j int) bool { return papers[i].PaperID < papers[j].PaperID })
+
+	report := &ExamLogChainReport{ExamID: examID, Broken: []*LogChainResult{}}
+	for _, paper := range papers {
+		logs, err := c.GetPaperAccessLogs(ctx, paper.PaperID)
+		if err != nil {
+			return nil, err
+		}
+		if len(logs) == 0 {
+			continue
+		}
+
+		result, err := verifyLogChain(ctx, paper.PaperID, logs)
+		if err != nil {
+			return nil, err
+		}
+		report.Checked++
+		if !result.Intact {
+			report.Broken = append(report.Broken, result)
+		}
+	}
+
+	return report, nil
+}
+
+// CountExamAccesses 统计考试中所有试卷在 [start, end] 时间段内的访问日志条数（RFC3339），action 为空时统计全部动作
+func (c *ExamPaperContract) CountExamAccesses(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+	action string,
+	startRFC3339 string,
+	endRFC3339 string,
+) (int, error) {
+	if examID == "" {
+		return 0, fmt.Errorf("examID is required")
+	}
+	start, err := parseTime(startRFC3339)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse start time: %v", err)
+	}
+	end, err := parseTime(endRFC3339)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse end time: %v", err)
+	}
+	if end.Before(start) {
+		return 0, fmt.Errorf("end time must not be before start time")
+	}
+
+	papers, err := queryPapers(ctx, map[string]interface{}{
+		"exam_id":  examID,
+		"paper_id": map[string]interface{}{"$exists": true},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, paper := range papers {
+		logs, err := c.GetPaperAccessLogs(ctx, paper.PaperID)
+		if err != nil {
+			return 0, err
+		}
+		for _, log := range logs {
+			if action != "" && log.Action != action {
+				continue
+			}
+			timestamp, err := parseTime(log.Timestamp)
+			if err != nil || timestamp.Before(start) || timestamp.After(end) {
+				continue
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// knownAccessActions 链码及客户端已知的访问动作
+var knownAccessActions = []string{"upload", "encrypt", "chain", "view", "download", "decrypt", "unlock", "answer_key", "rename", "approve", "relock", "delete", "reserve", "patch", "probe", "checkin", "reschedule", "hold", "release_hold", "unlock_failed", "verify", "verify_failed", "link", "submit", "publish", "release_approve", "preview_release", "archive", "emergency", "freeze", "unfreeze", "graded", "key_release"}
+
+// GetDistinctActions 获取试卷日志中出现过的动作（去重、排序）
+func (c *ExamPaperContract) GetDistinctActions(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("AccessLog", []string{paperID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %v", err)
+	}
+	defer iterator.Close()
+
+	known := make(map[string]bool, len(knownAccessActions))
+	for _, a := range knownAccessActions {
+		known[a] = true
+	}
+
+	seen := make(map[string]bool)
+	knownSeen := 0
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var log AccessLog
+		err = json.Unmarshal(result.Value, &log)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[log.Action] {
+			continue
+		}
+		seen[log.Action] = true
+
+		// 所有已知动作都已出现时提前结束（未知动作只能在此之前被收集到）
+		if known[log.Action] {
+			knownSeen++
+			if knownSeen == len(knownAccessActions) {
+				break
+			}
+		}
+	}
+
+	actions := make([]string, 0, len(seen))
+	for a := range seen {
+		actions = append(actions, a)
+	}
+	sort.Strings(actions)
+
+	return actions, nil
+}
+
 // ===================== 历史查询 =====================
 
-// GetPaperHistory 获取试卷历史
-func (c *ExamPaperContract) GetPaperHistory(
-	ctx contractapi.TransactionContextInterface,
-	paperID string,
-) ([]map[string]interface{}, error) {
-	iterator, err := ctx.GetStub().GetHistoryForKey(paperID)
+// TimestampAudit 试卷时间戳审计结果
+type TimestampAudit struct {
+	PaperID    string   `json:"paper_id"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+	Valid      bool     `json:"valid"`
+	Violations []string `json:"violations"`
+}
+
+// AuditTimestamps 检查试卷时间戳的时序完整性：CreatedAt <= UpdatedAt，且历史中 UpdatedAt 单调不减
+func (c *ExamPaperContract) AuditTimestamps(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*TimestampAudit, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit := &TimestampAudit{
+		PaperID:    paperID,
+		CreatedAt:  paper.CreatedAt,
+		UpdatedAt:  paper.UpdatedAt,
+		Violations: []string{},
+	}
+
+	// 这里审计的是存储格式本身，只接受链码写入时使用的 RFC3339，不经过宽松的 parseTime
+	createdAt, err := time.Parse(time.RFC3339, paper.CreatedAt)
+	if err != nil {
+		audit.Violations = append(audit.Violations, fmt.Sprintf("created_at %q is not RFC3339", paper.CreatedAt))
+	}
+	updatedAt, err2 := time.Parse(time.RFC3339, paper.UpdatedAt)
+	if err2 != nil {
+		audit.Violations = append(audit.Violations, fmt.Sprintf("updated_at %q is not RFC3339", paper.UpdatedAt))
+	}
+	if err == nil && err2 == nil && updatedAt.Before(createdAt) {
+		audit.Violations = append(audit.Violations, fmt.Sprintf("updated_at %s is earlier than created_at %s", paper.UpdatedAt, paper.CreatedAt))
+	}
+
+	versions, err := getPaperVersions(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous time.Time
+	for _, v := range versions {
+		if v.IsDelete {
+			continue
+		}
+		current, err := parseTime(v.Paper.UpdatedAt)
+		if err != nil {
+			continue
+		}
+		if current.Before(previous) {
+			audit.Violations = append(audit.Violations, fmt.Sprintf("updated_at went backwards to %s in tx %s", v.Paper.UpdatedAt, v.TxID))
+		}
+		previous = current
+	}
+
+	audit.Valid = len(audit.Violations) == 0
+	return audit, nil
+}
+
+// UnlockEvent 历史中的一次解锁
+type UnlockEvent struct {
+	TxID          string `json:"tx_id"`
+	UnlockedAt    string `json:"unlocked_at"`    // 解锁交易的时间
+	ScheduledTime string `json:"scheduled_time"` // 解锁时生效的 UnlockTime
+	Compliant     bool   `json:"compliant"`      // 解锁不早于计划时间
+}
+
+// UnlockTimingAudit 解锁时机审计结果，最近一次解锁的信息同时展开在顶层
+type UnlockTimingAudit struct {
+	PaperID       string         `json:"paper_id"`
+	UnlockedAt    string         `json:"unlocked_at"`
+	ScheduledTime string         `json:"scheduled_time"`
+	Compliant     bool           `json:"compliant"` // 历史中的每次解锁都不早于计划时间
+	Unlocks       []*UnlockEvent `json:"unlocks"`
+}
+
+// AuditUnlockTiming 从试卷历史中找出每次状态变为 unlocked 的交易，核对其时间不早于当时的 UnlockTime
+func (c *ExamPaperContract) AuditUnlockTiming(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*UnlockTimingAudit, error) {
+	versions, err := getPaperVersions(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit := &UnlockTimingAudit{PaperID: paperID, Compliant: true, Unlocks: []*UnlockEvent{}}
+	previousStatus := ""
+	for _, v := range versions {
+		if v.IsDelete {
+			previousStatus = ""
+			continue
+		}
+		status := v.Paper.Status
+		if status == "unlocked" && previousStatus != "unlocked" {
+			event := &UnlockEvent{
+				TxID:          v.TxID,
+				UnlockedAt:    v.Timestamp.UTC().Format(time.RFC3339),
+				ScheduledTime: v.Paper.UnlockTime,
+			}
+			scheduled, err := parseTime(v.Paper.UnlockTime)
+			event.Compliant = err == nil && !v.Timestamp.Before(scheduled)
+			if !event.Compliant {
+				audit.Compliant = false
+			}
+			audit.Unlocks = append(audit.Unlocks, event)
+		}
+		previousStatus = status
+	}
+
+	if len(audit.Unlocks) == 0 {
+		return nil, fmt.Errorf("paper %s has never been unlocked", paperID)
+	}
+
+	latest := audit.Unlocks[len(audit.Unlocks)-1]
+	audit.UnlockedAt = latest.UnlockedAt
+	audit.ScheduledTime = latest.ScheduledTime
+	return audit, nil
+}
+
+// GetPaperHistory 获取试卷历史
+func (c *ExamPaperContract) GetPaperHistory(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]map[string]interface{}, error) {
+	iterator, err := ctx.GetStub().GetHistoryForKey(paperID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history: %v", err)
+	}
+	defer iterator.Close()
+
+	var history []map[string]interface{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var paper ExamPaper
+		if len(result.Value) > 0 {
+			err = json.Unmarshal(result.Value, &paper)
+			if err != nil {
+				continue
+			}
+		}
+
+		record := map[string]interface{}{
+			"tx_id":     result.TxId,
+			"timestamp": time.Unix(result.Timestamp.Seconds, int64(result.Timestamp.Nanos)).Format(time.RFC3339),
+			"is_delete": result.IsDelete,
+			"paper":     paper,
+		}
+		history = append(history, record)
+	}
+
+	return history, nil
+}
+
+// 时间线事件来源
+const (
+	timelineSourceHistory   = "history"    // 试卷状态变更（账本历史）
+	timelineSourceAccessLog = "access_log" // 访问日志
+)
+
+// TimelineEvent 试卷时间线中的一条事件
+type TimelineEvent struct {
+	Timestamp      string `json:"timestamp"`
+	Source         string `json:"source"`
+	TxID           string `json:"tx_id,omitempty"`           // 状态变更所在交易
+	Status         string `json:"status,omitempty"`          // 变更后的状态，删除时为 deleted
+	PreviousStatus string `json:"previous_status,omitempty"` // 变更前的状态
+	LogID          string `json:"log_id,omitempty"`
+	Action         string `json:"action,omitempty"`
+	UserID         string `json:"user_id,omitempty"`
+	Details        string `json:"details,omitempty"`
+}
+
+// GetPaperTimeline 将试卷的状态变更（账本历史）与访问日志按时间合并为一条时间线，供事件调查使用
+// 时间相同时状态变更排在访问日志之前
+func (c *ExamPaperContract) GetPaperTimeline(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]*TimelineEvent, error) {
+	versions, err := getPaperVersions(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := []*TimelineEvent{}
+	previousStatus := ""
+	for _, v := range versions {
+		status := v.Paper.Status
+		if v.IsDelete {
+			status = "deleted"
+		}
+		if status == previousStatus {
+			continue
+		}
+		timeline = append(timeline, &TimelineEvent{
+			Timestamp:      v.Timestamp.Format(time.RFC3339),
+			Source:         timelineSourceHistory,
+			TxID:           v.TxID,
+			Status:         status,
+			PreviousStatus: previousStatus,
+		})
+		previousStatus = status
+	}
+
+	logs, err := c.GetPaperAccessLogs(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	for _, log := range logs {
+		timeline = append(timeline, &TimelineEvent{
+			Timestamp: log.Timestamp,
+			Source:    timelineSourceAccessLog,
+			LogID:     log.LogID,
+			Action:    log.Action,
+			UserID:    log.UserID,
+			Details:   log.Details,
+		})
+	}
+
+	// 稳定排序：时间相同的事件保持历史顺序和日志键顺序
+	sort.SliceStable(timeline, func(i, j int) bool {
+		a, b := timeline[i], timeline[j]
+		if a.Timestamp != b.Timestamp {
+			return a.Timestamp < b.Timestamp
+		}
+		return a.Source == timelineSourceHistory && b.Source != timelineSourceHistory
+	})
+
+	return timeline, nil
+}
+
+// GetMutationCount 获取试卷记录被写入的次数（即 Revision，每次写入递增，无需遍历历史）
+// 远超正常流程写入次数的试卷可能被篡改或被异常客户端反复修改
+func (c *ExamPaperContract) GetMutationCount(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (int, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return 0, err
+	}
+	return paper.Revision, nil
+}
+
+// ===================== 批量查询 =====================
+
+// GetAllPapers 获取所有试卷（分页），bookmark 为空表示从第一页开始
+// 带 paper_id 的非试卷记录会被过滤，因此一页返回的试卷可能少于 pageSize
+func (c *ExamPaperContract) GetAllPapers(
+	ctx contractapi.TransactionContextInterface,
+	pageSize int32,
+	bookmark string,
+) (*PaginatedResult, error) {
+	if err := validateBookmark(bookmark); err != nil {
+		return nil, err
+	}
+
+	query := `{"selector":{"paper_id":{"$gt":""}}}`
+
+	iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %v", err)
+	}
+	defer iterator.Close()
+
+	var papers []*ExamPaper
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// 访问日志、答案承诺版本等复合键记录同样带有 paper_id，只保留键等于试卷ID的试卷记录
+		var paper ExamPaper
+		err = json.Unmarshal(result.Value, &paper)
+		if err != nil {
+			continue
+		}
+		if paper.PaperID == "" || paper.PaperID != result.Key {
+			continue
+		}
+		papers = append(papers, &paper)
+	}
+
+	visible, err := redactPapersForCaller(ctx, papers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedResult{
+		Papers:      visible,
+		RecordCount: metadata.FetchedRecordsCount,
+		Bookmark:    metadata.Bookmark,
+	}, nil
+}
+
+// GetAllPapersStable 按试卷键顺序分页遍历全部试卷，书签为上一页最后的键
+// 与基于 selector 的 GetAllPapers 不同，并发写入不会导致已有试卷被跳过或重复返回（翻页期间新增的试卷若键排在书签之前则不在本轮遍历中）
+func (c *ExamPaperContract) GetAllPapersStable(
+	ctx contractapi.TransactionContextInterface,
+	pageSize int32,
+	bookmark string,
+) (*PaginatedResult, error) {
+	return c.GetPapersByKeyRange(ctx, "", "", pageSize, bookmark)
+}
+
+// GetPapersByKeyRange 按键范围 [startKey, endKey) 分页扫描试卷，不依赖 CouchDB 富查询（LevelDB 节点可用）
+// 非试卷记录（配置等）会被过滤，RecordCount 为扫描到的键数，因此一页返回的试卷可能少于 pageSize
+func (c *ExamPaperContract) GetPapersByKeyRange(
+	ctx contractapi.TransactionContextInterface,
+	startKey string,
+	endKey string,
+	pageSize int32,
+	bookmark string,
+) (*PaginatedResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be positive")
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state by range: %v", err)
+	}
+	defer iterator.Close()
+
+	papers := []*ExamPaper{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// 复合键（日志、索引等）以 0x00 开头，范围查询通常不会返回，这里再做防御性过滤
+		if strings.HasPrefix(result.Key, "\x00") {
+			continue
+		}
+
+		var paper ExamPaper
+		if err := json.Unmarshal(result.Value, &paper); err != nil {
+			continue
+		}
+		if paper.PaperID == "" || paper.PaperID != result.Key {
+			continue
+		}
+		papers = append(papers, &paper)
+	}
+
+	visible, err := redactPapersForCaller(ctx, papers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedResult{
+		Papers:      visible,
+		RecordCount: metadata.FetchedRecordsCount,
+		Bookmark:    metadata.Bookmark,
+	}, nil
+}
+
+// PaginatedResult 分页结果
+type PaginatedResult struct {
+	Papers      []*ExamPaper `json:"papers"`
+	RecordCount int32        `json:"record_count"`
+	Bookmark    string       `json:"bookmark"`
+}
+
+// GetPapersByExam 按考试ID查询试卷，返回结果按调用者权限隐去敏感字段
+func (c *ExamPaperContract) GetPapersByExam(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+) ([]*ExamPaper, error) {
+	papers, err := getExamPapers(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+	return redactPapersForCaller(ctx, papers)
+}
+
+// getExamPapers 查询考试的全部试卷（未隐去字段，供需要完整记录或写回的内部逻辑使用）
+func getExamPapers(ctx contractapi.TransactionContextInterface, examID string) ([]*ExamPaper, error) {
+	// paper_id 条件排除同样带有 exam_id 的考试登记和上传令牌记录
+	return queryPapers(ctx, map[string]interface{}{
+		"exam_id":  examID,
+		"paper_id": map[string]interface{}{"$exists": true},
+	})
+}
+
+// maxExamIDsPerQuery GetPapersByExams 单次可查询的考试数上限
+const maxExamIDsPerQuery = 50
+
+// GetPapersByExams 分页查询多个考试的试卷，examIDsJSON 为考试ID的 JSON 数组
+func (c *ExamPaperContract) GetPapersByExams(
+	ctx contractapi.TransactionContextInterface,
+	examIDsJSON string,
+	pageSize int32,
+	bookmark string,
+) (*PaginatedResult, error) {
+	var examIDs []string
+	if err := json.Unmarshal([]byte(examIDsJSON), &examIDs); err != nil {
+		return nil, fmt.Errorf("invalid examIDs: %v", err)
+	}
+	if len(examIDs) == 0 {
+		return nil, fmt.Errorf("at least one examID is required")
+	}
+	if len(examIDs) > maxExamIDsPerQuery {
+		return nil, fmt.Errorf("too many examIDs: %d (max %d)", len(examIDs), maxExamIDsPerQuery)
+	}
+	for _, examID := range examIDs {
+		if examID == "" {
+			return nil, fmt.Errorf("examIDs must not contain empty values")
+		}
+	}
+	if err := validateBookmark(bookmark); err != nil {
+		return nil, err
+	}
+
+	// paper_id 条件排除同样带有 exam_id 的考试登记记录
+	query, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"exam_id":  map[string]interface{}{"$in": examIDs},
+			"paper_id": map[string]interface{}{"$exists": true},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(query), pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %v", err)
+	}
+	defer iterator.Close()
+
+	papers := []*ExamPaper{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var paper ExamPaper
+		if err := json.Unmarshal(result.Value, &paper); err != nil {
+			continue
+		}
+		papers = append(papers, &paper)
+	}
+
+	visible, err := redactPapersForCaller(ctx, papers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedResult{
+		Papers:      visible,
+		RecordCount: metadata.FetchedRecordsCount,
+		Bookmark:    metadata.Bookmark,
+	}, nil
+}
+
+// GetPapersByStatus 按状态查询试卷
+func (c *ExamPaperContract) GetPapersByStatus(
+	ctx contractapi.TransactionContextInterface,
+	status string,
+) ([]*ExamPaper, error) {
+	if status == "" {
+		return nil, fmt.Errorf("status is required")
+	}
+	return queryVisiblePapers(ctx, map[string]interface{}{"status": status})
+}
+
+// GetStaleDrafts 获取在 olderThanRFC3339 之前预留、至今仍为草稿的试卷（含创建时间和预留人），按创建时间从早到晚排序
+// 用于在通过 DeletePaper 清理前通知预留人
+func (c *ExamPaperContract) GetStaleDrafts(
+	ctx contractapi.TransactionContextInterface,
+	olderThanRFC3339 string,
+) ([]*ExamPaper, error) {
+	cutoff, err := parseTime(olderThanRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cutoff time: %v", err)
+	}
+
+	drafts, err := queryPapers(ctx, map[string]interface{}{"status": "draft"})
+	if err != nil {
+		return nil, err
+	}
+
+	stale := []*ExamPaper{}
+	for _, paper := range drafts {
+		createdAt, err := parseTime(paper.CreatedAt)
+		if err != nil || !createdAt.Before(cutoff) {
+			continue
+		}
+		stale = append(stale, paper)
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		if stale[i].CreatedAt != stale[j].CreatedAt {
+			return stale[i].CreatedAt < stale[j].CreatedAt
+		}
+		return stale[i].PaperID < stale[j].PaperID
+	})
+
+	return redactPapersForCaller(ctx, stale)
+}
+
+// GetPapersByUploader 按上传者查询试卷
+func (c *ExamPaperContract) GetPapersByUploader(
+	ctx contractapi.TransactionContextInterface,
+	uploadedBy string,
+) ([]*ExamPaper, error) {
+	if uploadedBy == "" {
+		return nil, fmt.Errorf("uploadedBy is required")
+	}
+	return queryVisiblePapers(ctx, map[string]interface{}{"uploaded_by": uploadedBy})
+}
+
+// GetPapersByUploaderAndDateRange 查询指定上传者在 [start, end] 时间段内创建的试卷（RFC3339）
+func (c *ExamPaperContract) GetPapersByUploaderAndDateRange(
+	ctx contractapi.TransactionContextInterface,
+	uploaderID string,
+	startRFC3339 string,
+	endRFC3339 string,
+) ([]*ExamPaper, error) {
+	if uploaderID == "" {
+		return nil, fmt.Errorf("uploaderID is required")
+	}
+
+	start, err := parseTime(startRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse start time: %v", err)
+	}
+	end, err := parseTime(endRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse end time: %v", err)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end time must not be before start time")
+	}
+
+	// created_at 以 UTC RFC3339 存储，统一格式后可按字符串比较
+	return queryVisiblePapers(ctx, map[string]interface{}{
+		"uploaded_by": uploaderID,
+		"created_at": map[string]interface{}{
+			"$gte": start.UTC().Format(time.RFC3339),
+			"$lte": end.UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// GetPapersByDepartment 按院系（系）查询试卷
+func (c *ExamPaperContract) GetPapersByDepartment(
+	ctx contractapi.TransactionContextInterface,
+	department string,
+) ([]*ExamPaper, error) {
+	if department == "" {
+		return nil, fmt.Errorf("department is required")
+	}
+	return queryVisiblePapers(ctx, map[string]interface{}{"department": department})
+}
+
+// GetPapersByFaculty 按学部（学院）查询试卷
+func (c *ExamPaperContract) GetPapersByFaculty(
+	ctx contractapi.TransactionContextInterface,
+	faculty string,
+) ([]*ExamPaper, error) {
+	if faculty == "" {
+		return nil, fmt.Errorf("faculty is required")
+	}
+	return queryVisiblePapers(ctx, map[string]interface{}{"faculty": faculty})
+}
+
+// GetPapersByOrg 查询由指定组织（MSP ID）存储的试卷
+func (c *ExamPaperContract) GetPapersByOrg(
+	ctx contractapi.TransactionContextInterface,
+	mspID string,
+) ([]*ExamPaper, error) {
+	if mspID == "" {
+		return nil, fmt.Errorf("mspID is required")
+	}
+	return queryVisiblePapers(ctx, map[string]interface{}{"creator_msp": mspID})
+}
+
+// immutablePaperFields 创建后不可修改的字段，PatchPaper 拒绝修改，savePaper 覆盖已有记录时统一校验
+var immutablePaperFields = map[string]bool{
+	"paper_id":    true,
+	"exam_id":     true,
+	"file_hash":   true,
+	"created_at":  true,
+	"uploaded_by": true,
+	"creator_msp": true,
+}
+
+// patchablePaperFields 允许通过 PatchPaper 修改的字段
+var patchablePaperFields = map[string]bool{
+	"subject":      true,
+	"department":   true,
+	"faculty":      true,
+	"exam_name":    true,
+	"cover_sheet":  true,
+	"content_type": true,
+	"tags":         true,
+}
+
+// validTransitions 试卷状态转换表
+var validTransitions = map[string][]string{
+	"draft":    {"locked"},
+	"locked":   {"unlocked"},
+	"unlocked": {"archived", "locked"},
+}
+
+// guardedTransitions 只能由专用方法触发、不允许通过 UpdatePaperStatus 执行的状态转换
+var guardedTransitions = map[string]bool{
+	"draft->locked":    true, // StorePaper 填充预留草稿
+	"locked->unlocked": true, // UnlockPaper 等经过 unlockBlockReason / unlockGateReason 检查的解锁方法
+	"unlocked->locked": true, // Relock
+}
+
+// checkStatusChange 验证试卷的状态转换：已公开的试卷处于终态，不允许任何状态变更
+func checkStatusChange(paper *ExamPaper, to string, privileged bool) error {
+	if paper.Published {
+		return fmt.Errorf("paper %s is published, published papers are terminal and cannot change status", paper.PaperID)
+	}
+	return checkTransition(paper.Status, to, privileged)
+}
+
+// checkTransition 验证状态转换是否合法，privileged 表示调用方为可执行受保护转换的专用方法
+func checkTransition(from string, to string, privileged bool) error {
+	allowed := false
+	for _, s := range validTransitions[from] {
+		if s == to {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed || (guardedTransitions[from+"->"+to] && !privileged) {
+		return fmt.Errorf("invalid status transition from %s to %s", from, to)
+	}
+	return nil
+}
+
+// paperStatuses 试卷的全部状态
+var paperStatuses = []string{"draft", "locked", "unlocked", "archived"}
+
+// GetStatusBreakdown 按状态统计试卷数量，没有试卷的状态也返回 0
+func (c *ExamPaperContract) GetStatusBreakdown(
+	ctx contractapi.TransactionContextInterface,
+) (map[string]int, error) {
+	papers, err := queryPapers(ctx, map[string]interface{}{"status": map[string]interface{}{"$exists": true}})
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown := make(map[string]int, len(paperStatuses))
+	for _, status := range paperStatuses {
+		breakdown[status] = 0
+	}
+	for _, paper := range papers {
+		breakdown[paper.Status]++
+	}
+
+	return breakdown, nil
+}
+
+// GetActiveExamWindowPapers 获取当前处于考试窗口内的试卷（已归档的除外）
+func (c *ExamPaperContract) GetActiveExamWindowPapers(
+	ctx contractapi.TransactionContextInterface,
+) ([]*ExamPaper, error) {
+	papers, err := queryPapers(ctx, map[string]interface{}{"status": map[string]interface{}{"$in": []string{"locked", "unlocked"}}})
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	active := []*ExamPaper{}
+	for _, paper := range papers {
+		state, err := examWindowState(paper, now)
+		if err != nil {
+			continue
+		}
+		if state == windowOpen {
+			active = append(active, paper)
+		}
+	}
+
+	return redactPapersForCaller(ctx, active)
+}
+
+// ExamUnlockSummary 单场考试的试卷解锁情况
+type ExamUnlockSummary struct {
+	ExamID        string `json:"exam_id"`
+	Locked        int    `json:"locked"`         // 已锁定且当前不可解锁（未到时间或窗口已关闭）
+	UnlockableNow int    `json:"unlockable_now"` // 已锁定且当前处于考试窗口内
+	Unlocked      int    `json:"unlocked"`
+	Unparseable   int    `json:"unparseable"` // 时间无法解析而未计入上述分类的已锁定试卷
+}
+
+// GetExamUnlockDashboard 按考试统计试卷的锁定/可解锁/已解锁数量，以交易时间为准，按 ExamID 排序
+func (c *ExamPaperContract) GetExamUnlockDashboard(
+	ctx contractapi.TransactionContextInterface,
+) ([]*ExamUnlockSummary, error) {
+	papers, err := queryPapers(ctx, map[string]interface{}{"status": map[string]interface{}{"$in": []string{"locked", "unlocked"}}})
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[string]*ExamUnlockSummary)
+	for _, paper := range papers {
+		summary, exists := summaries[paper.ExamID]
+		if !exists {
+			summary = &ExamUnlockSummary{ExamID: paper.ExamID}
+			summaries[paper.ExamID] = summary
+		}
+
+		if paper.Status == "unlocked" {
+			summary.Unlocked++
+			continue
+		}
+
+		state, err := examWindowState(paper, now)
+		if err != nil {
+			summary.Unparseable++
+			continue
+		}
+		if state == windowOpen {
+			summary.UnlockableNow++
+		} else {
+			summary.Locked++
+		}
+	}
+
+	dashboard := make([]*ExamUnlockSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		dashboard = append(dashboard, summary)
+	}
+	sort.Slice(dashboard, func(i, j int) bool { return dashboard[i].ExamID < dashboard[j].ExamID })
+
+	return dashboard, nil
+}
+
+// UnlockTimeBucket 解锁时间直方图的一个时间段
+type UnlockTimeBucket struct {
+	Start string `json:"start"` // 时间段起点（含）
+	End   string `json:"end"`   // 时间段终点（不含）
+	Count int    `json:"count"`
+}
+
+// UnlockTimeHistogram 考试试卷解锁时间的分布
+type UnlockTimeHistogram struct {
+	ExamID        string              `json:"exam_id"`
+	BucketSeconds int64               `json:"bucket_seconds"`
+	Buckets       []*UnlockTimeBucket `json:"buckets"` // 按时间排序，只包含有试卷的时间段
+	Skipped       int                 `json:"skipped"` // 解锁时间无法解析而跳过的试卷数
+}
+
+// GetUnlockTimeHistogram 按 bucketSeconds 长度的时间段统计考试试卷的解锁时间分布，用于发现集中解锁
+func (c *ExamPaperContract) GetUnlockTimeHistogram(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+	bucketSeconds int64,
+) (*UnlockTimeHistogram, error) {
+	if examID == "" {
+		return nil, fmt.Errorf("examID is required")
+	}
+	if bucketSeconds <= 0 {
+		return nil, fmt.Errorf("bucketSeconds must be positive")
+	}
+
+	papers, err := queryPapers(ctx, map[string]interface{}{
+		"exam_id":  examID,
+		"paper_id": map[string]interface{}{"$exists": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	histogram := &UnlockTimeHistogram{ExamID: examID, BucketSeconds: bucketSeconds, Buckets: []*UnlockTimeBucket{}}
+	counts := make(map[int64]int)
+	for _, paper := range papers {
+		unlockTime, err := parseTime(paper.UnlockTime)
+		if err != nil {
+			histogram.Skipped++
+			continue
+		}
+		// 向下取整到时间段起点（负数时间戳同样向下取整）
+		unix := unlockTime.Unix()
+		start := unix - unix%bucketSeconds
+		if unix%bucketSeconds < 0 {
+			start -= bucketSeconds
+		}
+		counts[start]++
+	}
+
+	starts := make([]int64, 0, len(counts))
+	for start := range counts {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	for _, start := range starts {
+		histogram.Buckets = append(histogram.Buckets, &UnlockTimeBucket{
+			Start: time.Unix(start, 0).UTC().Format(time.RFC3339),
+			End:   time.Unix(start+bucketSeconds, 0).UTC().Format(time.RFC3339),
+			Count: counts[start],
+		})
+	}
+
+	return histogram, nil
+}
+
+// PaperReadiness 单份试卷的开考前检查结果
+type PaperReadiness struct {
+	PaperID string   `json:"paper_id"`
+	Ready   bool     `json:"ready"`
+	Issues  []string `json:"issues"`
+}
+
+// ExamReadinessReport 考试开考前检查结果
+type ExamReadinessReport struct {
+	ExamID string            `json:"exam_id"`
+	Ready  bool              `json:"ready"` // 考试至少有一份试卷且全部就绪
+	Papers []*PaperReadiness `json:"papers"`
+}
+
+// CheckExamReadiness 开考前检查考试中的每份试卷：已锁定、解锁时间在未来、IPFS 与文件哈希齐全、
+// 已存储答案承诺、未被暂扣、最近一次探测内容可用
+func (c *ExamPaperContract) CheckExamReadiness(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+) (*ExamReadinessReport, error) {
+	papers, err := queryPapers(ctx, map[string]interface{}{
+		"exam_id":  examID,
+		"paper_id": map[string]interface{}{"$exists": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(papers, func(i, j int) bool { return papers[i].PaperID < papers[j].PaperID })
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ExamReadinessReport{ExamID: examID, Ready: len(papers) > 0, Papers: []*PaperReadiness{}}
+	for _, paper := range papers {
+		issues := []string{}
+
+		if paper.Status != "locked" {
+			issues = append(issues, fmt.Sprintf("status is %s, expected locked", paper.Status))
+		}
+		if paper.UnlockTime == "" {
+			issues = append(issues, "unlock time is not set")
+		} else if unlockTime, err := parseTime(paper.UnlockTime); err != nil {
+			issues = append(issues, fmt.Sprintf("unlock time %s cannot be parsed", paper.UnlockTime))
+		} else if !unlockTime.After(now) {
+			issues = append(issues, fmt.Sprintf("unlock time %s is not in the future", paper.UnlockTime))
+		}
+		if paper.IPFSHash == "" {
+			issues = append(issues, "IPFS hash is missing")
+		}
+		if paper.FileHash == "" {
+			issues = append(issues, "file hash is missing")
+		}
+		if paper.AnswerKeyCommitment == "" {
+			issues = append(issues, "answer key commitment is missing")
+		}
+		if paper.HoldReason != "" {
+			issues = append(issues, fmt.Sprintf("paper is on hold: %s", paper.HoldReason))
+		}
+		switch paper.ProbeStatus {
+		case probeAvailable:
+		case "":
+			issues = append(issues, "content availability has never been probed")
+		default:
+			issues = append(issues, fmt.Sprintf("content was %s at last probe (%s)", paper.ProbeStatus, paper.LastProbedAt))
+		}
+
+		ready := len(issues) == 0
+		if !ready {
+			report.Ready = false
+		}
+		report.Papers = append(report.Papers, &PaperReadiness{PaperID: paper.PaperID, Ready: ready, Issues: issues})
+	}
+
+	return report, nil
+}
+
+// GetDeletableArchivedPapers 获取保留期限已过、可以删除的归档试卷
+func (c *ExamPaperContract) GetDeletableArchivedPapers(
+	ctx contractapi.TransactionContextInterface,
+) ([]*ExamPaper, error) {
+	papers, err := queryPapers(ctx, map[string]interface{}{"status": "archived"})
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deletable := []*ExamPaper{}
+	for _, paper := range papers {
+		expired, err := retentionExpired(paper, now)
+		if err != nil {
+			continue
+		}
+		if expired {
+			deletable = append(deletable, paper)
+		}
+	}
+
+	return redactPapersForCaller(ctx, deletable)
+}
+
+// CountPapersByUploader 按上传者统计试卷数量（基于上传者索引，索引建立前上传的试卷不计入）
+func (c *ExamPaperContract) CountPapersByUploader(
+	ctx contractapi.TransactionContextInterface,
+) (map[string]int, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(uploaderIndex, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uploader index: %v", err)
+	}
+	defer iterator.Close()
+
+	counts := make(map[string]int)
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(result.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+		counts[attrs[0]]++
+	}
+
+	return counts, nil
+}
+
+// DuplicateFileHashGroup 使用相同文件哈希的一组试卷
+type DuplicateFileHashGroup struct {
+	FileHash string   `json:"file_hash"`
+	PaperIDs []string `json:"paper_ids"`
+}
+
+// FindDuplicateFileHashes 基于文件哈希索引查找被多份试卷共用的文件哈希
+func (c *ExamPaperContract) FindDuplicateFileHashes(
+	ctx contractapi.TransactionContextInterface,
+) ([]*DuplicateFileHashGroup, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(fileHashIndex, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file hash index: %v", err)
+	}
+	defer iterator.Close()
+
+	// 索引按 fileHash 排序，相同哈希的条目相邻
+	groups := []*DuplicateFileHashGroup{}
+	var current *DuplicateFileHashGroup
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(result.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+
+		if current == nil || current.FileHash != attrs[0] {
+			if current != nil && len(current.PaperIDs) > 1 {
+				groups = append(groups, current)
+			}
+			current = &DuplicateFileHashGroup{FileHash: attrs[0]}
+		}
+		current.PaperIDs = append(current.PaperIDs, attrs[1])
+	}
+	if current != nil && len(current.PaperIDs) > 1 {
+		groups = append(groups, current)
+	}
+
+	return groups, nil
+}
+
+// FindPaperByFileHash 通过文件哈希索引查找使用该文件哈希的全部试卷（可能有重复），没有匹配时返回空列表
+// 供泄题检测判断外泄文件是否属于已存储的试卷；返回结果按调用者权限隐去敏感字段
+func (c *ExamPaperContract) FindPaperByFileHash(
+	ctx contractapi.TransactionContextInterface,
+	fileHash string,
+) ([]*ExamPaper, error) {
+	if fileHash == "" {
+		return nil, fmt.Errorf("fileHash is required")
+	}
+
+	paperIDs, err := getPaperIDsByIndex(ctx, fileHashIndex, fileHash)
+	if err != nil {
+		return nil, err
+	}
+
+	papers := []*ExamPaper{}
+	for _, paperID := range paperIDs {
+		paper, err := getPaper(ctx, paperID)
+		if err != nil {
+			return nil, err
+		}
+		visible, err := redactForCaller(ctx, paper)
+		if err != nil {
+			return nil, err
+		}
+		papers = append(papers, visible)
+	}
+
+	return papers, nil
+}
+
+// ===================== 验证 =====================
+
+// ComplianceReport 试卷合规报告
+type ComplianceReport struct {
+	PaperID       string         `json:"paper_id"`
+	ExamID        string         `json:"exam_id"`
+	UploadedBy    string         `json:"uploaded_by"`
+	CreatedAt     string         `json:"created_at"`
+	Status        string         `json:"status"`
+	StatusChanges int            `json:"status_changes"` // 历史中的状态变更次数
+	AccessCounts  map[string]int `json:"access_counts"`  // 按动作统计的访问次数
+	TotalAccesses int            `json:"total_accesses"`
+	HashVerified  bool           `json:"hash_verified"` // 提供的哈希与存储的 FileHash 一致
+	MetaIntact    bool           `json:"meta_intact"`   // 不可变元数据指纹校验通过
+}
+
+// GetComplianceReport 汇总试卷记录、历史和访问日志，生成一次调用的合规报告
+func (c *ExamPaperContract) GetComplianceReport(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	providedHash string,
+) (*ComplianceReport, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ComplianceReport{
+		PaperID:      paper.PaperID,
+		ExamID:       paper.ExamID,
+		UploadedBy:   paper.UploadedBy,
+		CreatedAt:    paper.CreatedAt,
+		Status:       paper.Status,
+		AccessCounts: make(map[string]int),
+	}
+
+	versions, err := getPaperVersions(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	previousStatus := ""
+	for _, v := range versions {
+		if v.IsDelete {
+			previousStatus = ""
+			continue
+		}
+		if previousStatus != "" && v.Paper.Status != previousStatus {
+			report.StatusChanges++
+		}
+		previousStatus = v.Paper.Status
+	}
+
+	logs, err := c.GetPaperAccessLogs(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	for _, log := range logs {
+		report.AccessCounts[log.Action]++
+	}
+	report.TotalAccesses = len(logs)
+
+	report.HashVerified, err = c.VerifyPaperHash(ctx, paperID, providedHash, false)
+	if err != nil {
+		return nil, err
+	}
+
+	integrity, err := c.VerifyMetaIntegrity(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	report.MetaIntact = integrity.Intact
+
+	return report, nil
+}
+
+// VerifyPaperHash 验证试卷哈希，logResult 为 true 时记录校验日志（成功为 verify，失败为 verify_failed）
+func (c *ExamPaperContract) VerifyPaperHash(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	providedHash string,
+	logResult bool,
+) (bool, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return false, err
+	}
+
+	matched := paper.FileHash == providedHash
+	if !logResult {
+		return matched, nil
+	}
+
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+	if matched {
+		err = recordAccess(ctx, paperID, userID, "verify", "", "File hash verified")
+	} else {
+		err = recordAccess(ctx, paperID, userID, "verify_failed", "", "File hash mismatch")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return matched, nil
+}
+
+// GetUnverifiedPapers 获取考试中从未成功校验过文件哈希（没有 verify 日志）的试卷
+func (c *ExamPaperContract) GetUnverifiedPapers(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+) ([]*ExamPaper, error) {
+	papers, err := queryPapers(ctx, map[string]interface{}{
+		"exam_id":  examID,
+		"paper_id": map[string]interface{}{"$exists": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(papers, func(i, j int) bool { return papers[i].PaperID < papers[j].PaperID })
+
+	unverified := []*ExamPaper{}
+	for _, paper := range papers {
+		logs, err := c.GetPaperAccessLogs(ctx, paper.PaperID)
+		if err != nil {
+			return nil, err
+		}
+
+		verified := false
+		for _, log := range logs {
+			if log.Action == "verify" {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			unverified = append(unverified, paper)
+		}
+	}
+
+	return redactPapersForCaller(ctx, unverified)
+}
+
+// maxHashBatchSize 批量哈希校验的最大条目数
+const maxHashBatchSize = 100
+
+// HashCheck 批量哈希校验的输入条目
+type HashCheck struct {
+	PaperID      string `json:"paperID"`
+	ProvidedHash string `json:"providedHash"`
+}
+
+// HashCheckResult 批量哈希校验的单条结果
+type HashCheckResult struct {
+	PaperID string `json:"paper_id"`
+	Matched bool   `json:"matched"`
+	Found   bool   `json:"found"` // 试卷不存在时为 false
+}
+
+// VerifyHashBatch 批量验证试卷哈希，pairsJSON 为 [{paperID, providedHash}] 数组
+// 单个试卷不存在不会使整批失败，对应结果 found=false
+func (c *ExamPaperContract) VerifyHashBatch(
+	ctx contractapi.TransactionContextInterface,
+	pairsJSON string,
+) ([]*HashCheckResult, error) {
+	var pairs []HashCheck
+	if err := json.Unmarshal([]byte(pairsJSON), &pairs); err != nil {
+		return nil, fmt.Errorf("invalid pairs: %v", err)
+	}
+	if len(pairs) > maxHashBatchSize {
+		return nil, fmt.Errorf("batch size %d exceeds maximum of %d", len(pairs), maxHashBatchSize)
+	}
+
+	results := make([]*HashCheckResult, 0, len(pairs))
+	for _, pair := range pairs {
+		result := &HashCheckResult{PaperID: pair.PaperID}
+
+		paperJSON, err := ctx.GetStub().GetState(pair.PaperID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if paperJSON != nil {
+			var paper ExamPaper
+			if err := json.Unmarshal(paperJSON, &paper); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal paper: %v", err)
+			}
+			result.Found = true
+			result.Matched = paper.FileHash == pair.ProvidedHash
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// ManifestEntry 链下考试清单中的一条预期试卷
+type ManifestEntry struct {
+	PaperID  string `json:"paperID"`
+	FileHash string `json:"fileHash"`
+	IPFSHash string `json:"ipfsHash"`
+}
+
+// ManifestMismatch 链上与清单哈希不一致的试卷，只列出不一致的字段，不返回链上的值
+type ManifestMismatch struct {
+	PaperID string   `json:"paper_id"`
+	Fields  []string `json:"fields"` // file_hash、ipfs_hash
+}
+
+// ManifestReport 考试与链下清单的核对结果，各列表按试卷ID排序
+type ManifestReport struct {
+	ExamID         string              `json:"exam_id"`
+	Matched        []string            `json:"matched"`
+	Mismatched     []*ManifestMismatch `json:"mismatched"`
+	NotInManifest  []string            `json:"not_in_manifest"`  // 链上存在但清单中没有
+	MissingOnChain []string            `json:"missing_on_chain"` // 清单中有但考试在链上没有
+}
+
+// VerifyExamManifest 核对考试在链上的试卷与链下清单是否完全一致，manifestJSON 为 [{paperID, fileHash, ipfsHash}] 数组
+// 比较与顺序无关；清单中的试卷若在链上属于其他考试，按缺失处理
+func (c *ExamPaperContract) VerifyExamManifest(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+	manifestJSON string,
+) (*ManifestReport, error) {
+	if examID == "" {
+		return nil, fmt.Errorf("examID is required")
+	}
+
+	var entries []ManifestEntry
+	if err := json.Unmarshal([]byte(manifestJSON), &entries); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	manifest := make(map[string]ManifestEntry, len(entries))
+	for _, entry := range entries {
+		if entry.PaperID == "" {
+			return nil, fmt.Errorf("manifest entries must have a paperID")
+		}
+		if _, exists := manifest[entry.PaperID]; exists {
+			return nil, fmt.Errorf("paper %s appears more than once in the manifest", entry.PaperID)
+		}
+		manifest[entry.PaperID] = entry
+	}
+
+	papers, err := queryPapers(ctx, map[string]interface{}{
+		"exam_id":  examID,
+		"paper_id": map[string]interface{}{"$exists": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ManifestReport{
+		ExamID:         examID,
+		Matched:        []string{},
+		Mismatched:     []*ManifestMismatch{},
+		NotInManifest:  []string{},
+		MissingOnChain: []string{},
+	}
+	onChain := make(map[string]bool, len(papers))
+	for _, paper := range papers {
+		onChain[paper.PaperID] = true
+
+		entry, listed := manifest[paper.PaperID]
+		if !listed {
+			report.NotInManifest = append(report.NotInManifest, paper.PaperID)
+			continue
+		}
+
+		var fields []string
+		if paper.FileHash != entry.FileHash {
+			fields = append(fields, "file_hash")
+		}
+		if paper.IPFSHash != entry.IPFSHash {
+			fields = append(fields, "ipfs_hash")
+		}
+		if len(fields) > 0 {
+			report.Mismatched = append(report.Mismatched, &ManifestMismatch{PaperID: paper.PaperID, Fields: fields})
+		} else {
+			report.Matched = append(report.Matched, paper.PaperID)
+		}
+	}
+	for paperID := range manifest {
+		if !onChain[paperID] {
+			report.MissingOnChain = append(report.MissingOnChain, paperID)
+		}
+	}
+
+	sort.Strings(report.Matched)
+	sort.Slice(report.Mismatched, func(i, j int) bool { return report.Mismatched[i].PaperID < report.Mismatched[j].PaperID })
+	sort.Strings(report.NotInManifest)
+	sort.Strings(report.MissingOnChain)
+
+	return report, nil
+}
+
+// volatilePaperFields 随写入变化、不代表内容差异的字段，比较时照常报告但不计入实质差异
+var volatilePaperFields = map[string]bool{
+	"created_at":     true,
+	"updated_at":     true,
+	"revision":       true,
+	"meta_hash":      true, // 包含 paper_id，不同试卷必然不同
+	"last_probed_at": true,
+	"probe_status":   true,
+}
+
+// FieldDiff 两份试卷不同的字段（值为 JSON 表示）
+type FieldDiff struct {
+	Field    string `json:"field"`
+	ValueA   string `json:"value_a"`
+	ValueB   string `json:"value_b"`
+	Volatile bool   `json:"volatile"`
+}
+
+// PaperComparison 两份试卷元数据的比较结果
+type PaperComparison struct {
+	PaperIDA              string       `json:"paper_id_a"`
+	PaperIDB              string       `json:"paper_id_b"`
+	Matching              []string     `json:"matching"`
+	Differing             []*FieldDiff `json:"differing"`
+	MeaningfulDifferences bool         `json:"meaningful_differences"` // 存在非易变字段的差异
+}
+
+// ComparePapers 逐字段比较两份试卷的元数据（按调用者可见的内容），字段按名称排序，不比较 paper_id
+func (c *ExamPaperContract) ComparePapers(
+	ctx contractapi.TransactionContextInterface,
+	paperIDA string,
+	paperIDB string,
+) (*PaperComparison, error) {
+	paperA, err := c.GetPaper(ctx, paperIDA)
+	if err != nil {
+		return nil, err
+	}
+	paperB, err := c.GetPaper(ctx, paperIDB)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldsA, err := paperFields(paperA)
+	if err != nil {
+		return nil, err
+	}
+	fieldsB, err := paperFields(paperB)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range fieldsA {
+		names = append(names, name)
+	}
+	for name := range fieldsB {
+		if _, exists := fieldsA[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	comparison := &PaperComparison{
+		PaperIDA:  paperIDA,
+		PaperIDB:  paperIDB,
+		Matching:  []string{},
+		Differing: []*FieldDiff{},
+	}
+	for _, name := range names {
+		if name == "paper_id" {
+			continue
+		}
+
+		valueA, valueB := string(fieldsA[name]), string(fieldsB[name])
+		if valueA == valueB {
+			comparison.Matching = append(comparison.Matching, name)
+			continue
+		}
+
+		diff := &FieldDiff{Field: name, ValueA: valueA, ValueB: valueB, Volatile: volatilePaperFields[name]}
+		comparison.Differing = append(comparison.Differing, diff)
+		if !diff.Volatile {
+			comparison.MeaningfulDifferences = true
+		}
+	}
+
+	return comparison, nil
+}
+
+// paperFields 将试卷序列化为 JSON 字段名到 JSON 值的映射（省略的空字段不出现）
+func paperFields(paper *ExamPaper) (map[string]json.RawMessage, error) {
+	paperJSON, err := json.Marshal(paper)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal paper: %v", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(paperJSON, &fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal paper: %v", err)
+	}
+	return fields, nil
+}
+
+// ===================== 考试登记 =====================
+
+// RegisterExam 登记考试
+func (c *ExamPaperContract) RegisterExam(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+	name string,
+	requesterID string,
+) error {
+	if err := requireRole(ctx, "admin", "coe"); err != nil {
+		return err
+	}
+	if examID == "" || name == "" {
+		return fmt.Errorf("examID and name are required")
+	}
+
+	existing, err := getExam(ctx, examID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("exam %s already exists", examID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putExam(ctx, &Exam{
+		ExamID:    examID,
+		Name:      name,
+		CreatedBy: requesterID,
+		CreatedAt: now.Format(time.RFC3339),
+	})
+}
+
+// GetExam 获取考试登记信息
+func (c *ExamPaperContract) GetExam(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+) (*Exam, error) {
+	exam, err := getExam(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+	if exam == nil {
+		return nil, fmt.Errorf("exam %s does not exist", examID)
+	}
+	return exam, nil
+}
+
+// SetExamMaxConcurrentUnlocked 设置考试同时处于 unlocked 的试卷数上限（错峰开考的准入控制），0 表示不限制
+func (c *ExamPaperContract) SetExamMaxConcurrentUnlocked(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+	maxUnlocked int,
+) error {
+	if err := requireRole(ctx, "admin", "coe"); err != nil {
+		return err
+	}
+	if maxUnlocked < 0 {
+		return fmt.Errorf("maxUnlocked must not be negative")
+	}
+
+	exam, err := c.GetExam(ctx, examID)
+	if err != nil {
+		return err
+	}
+
+	exam.MaxConcurrentUnlocked = maxUnlocked
+	return putExam(ctx, exam)
+}
+
+// ReconcileExamNames 将考试下试卷的 ExamName 刷新为登记的考试名称，返回更新的试卷数
+func (c *ExamPaperContract) ReconcileExamNames(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+) (int, error) {
+	if err := requireRole(ctx, "admin", "coe"); err != nil {
+		return 0, err
+	}
+
+	exam, err := c.GetExam(ctx, examID)
+	if err != nil {
+		return 0, err
+	}
+
+	papers, err := getExamPapers(ctx, examID)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, paper := range papers {
+		if paper.ExamName == exam.Name {
+			continue
+		}
+		paper.ExamName = exam.Name
+		if err := savePaper(ctx, paper); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
+// ===================== 试卷模板 =====================
+
+// PaperTemplateFields 模板提供的试卷默认值，也是 StorePaperFromTemplate 覆盖项的格式
+type PaperTemplateFields struct {
+	ExamID     string        `json:"exam_id,omitempty"`
+	Subject    string        `json:"subject,omitempty"`
+	IPFSHash   string        `json:"ipfs_hash,omitempty"`
+	FileHash   string        `json:"file_hash,omitempty"`
+	UnlockTime string        `json:"unlock_time,omitempty"`
+	UploadedBy string        `json:"uploaded_by,omitempty"` // 为空时使用调用者ID
+	Options    *PaperOptions `json:"options,omitempty"`
+}
+
+// PaperTemplate 试卷模板，批量建卷时复用相同的科目、时长、解锁安排等
+type PaperTemplate struct {
+	TemplateID string              `json:"template_id"`
+	Defaults   PaperTemplateFields `json:"defaults"`
+	CreatedBy  string              `json:"created_by"`
+	CreatedAt  string              `json:"created_at"`
+}
+
+// paperTemplateKeyType 试卷模板的复合键类型
+const paperTemplateKeyType = "PaperTemplate"
+
+// CreateTemplate 创建试卷模板，templateJSON 为 PaperTemplateFields 的 JSON
+func (c *ExamPaperContract) CreateTemplate(
+	ctx contractapi.TransactionContextInterface,
+	templateID string,
+	templateJSON string,
+) error {
+	if err := requireRole(ctx, "admin", "coe"); err != nil {
+		return err
+	}
+	if templateID == "" {
+		return fmt.Errorf("templateID is required")
+	}
+
+	var defaults PaperTemplateFields
+	if err := json.Unmarshal([]byte(templateJSON), &defaults); err != nil {
+		return fmt.Errorf("invalid template: %v", err)
+	}
+	// 委托上传令牌只能使用一次，不能作为模板默认值
+	if defaults.Options != nil && defaults.Options.UploadToken != "" {
+		return fmt.Errorf("templates must not contain an upload token")
+	}
+
+	existing, err := getPaperTemplate(ctx, templateID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("template %s already exists", templateID)
+	}
+
+	creatorID, err := callerUserID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putPaperTemplate(ctx, &PaperTemplate{
+		TemplateID: templateID,
+		Defaults:   defaults,
+		CreatedBy:  creatorID,
+		CreatedAt:  now.Format(time.RFC3339),
+	})
+}
+
+// GetTemplate 获取试卷模板
+func (c *ExamPaperContract) GetTemplate(
+	ctx contractapi.TransactionContextInterface,
+	templateID string,
+) (*PaperTemplate, error) {
+	template, err := getPaperTemplate(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, fmt.Errorf("template %s does not exist", templateID)
+	}
+	return template, nil
+}
+
+// StorePaperFromTemplate 以模板默认值加覆盖项（PaperTemplateFields 的 JSON，options 按字段合并）生成并存储试卷
+// 结果与 StorePaperWithOptions 走相同的校验
+func (c *ExamPaperContract) StorePaperFromTemplate(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	templateID string,
+	overridesJSON string,
+) error {
+	template, err := c.GetTemplate(ctx, templateID)
+	if err != nil {
+		return err
+	}
+
+	fields, err := applyTemplateOverrides(&template.Defaults, overridesJSON)
+	if err != nil {
+		return err
+	}
+
+	uploadedBy := fields.UploadedBy
+	if uploadedBy == "" {
+		uploadedBy, err = callerUserID(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	optionsJSON := ""
+	if fields.Options != nil {
+		options, err := json.Marshal(fields.Options)
+		if err != nil {
+			return fmt.Errorf("failed to marshal options: %v", err)
+		}
+		optionsJSON = string(options)
+	}
+
+	return storePaper(ctx, paperID, fields.ExamID, fields.Subject, fields.IPFSHash, fields.FileHash, fields.UnlockTime, uploadedBy, optionsJSON, nil, "", nil)
+}
+
+// applyTemplateOverrides 在模板默认值上应用覆盖项：顶层字段直接替换，options 对象按字段合并
+func applyTemplateOverrides(defaults *PaperTemplateFields, overridesJSON string) (*PaperTemplateFields, error) {
+	defaultsJSON, err := json.Marshal(defaults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal template: %v", err)
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(defaultsJSON, &merged); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal template: %v", err)
+	}
+
+	if overridesJSON != "" {
+		var overrides map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(overridesJSON), &overrides); err != nil {
+			return nil, fmt.Errorf("invalid overrides: %v", err)
+		}
+		for field, value := range overrides {
+			base, ok := merged["options"]
+			if field != "options" || !ok {
+				merged[field] = value
+				continue
+			}
+
+			var options map[string]json.RawMessage
+			if err := json.Unmarshal(base, &options); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal template options: %v", err)
+			}
+			var optionOverrides map[string]json.RawMessage
+			if err := json.Unmarshal(value, &optionOverrides); err != nil {
+				return nil, fmt.Errorf("invalid options override: %v", err)
+			}
+			for key, v := range optionOverrides {
+				options[key] = v
+			}
+			if merged["options"], err = json.Marshal(options); err != nil {
+				return nil, fmt.Errorf("failed to marshal options: %v", err)
+			}
+		}
+	}
+
+	mergedJSON, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal merged template: %v", err)
+	}
+	var fields PaperTemplateFields
+	if err := json.Unmarshal(mergedJSON, &fields); err != nil {
+		return nil, fmt.Errorf("invalid override value: %v", err)
+	}
+	return &fields, nil
+}
+
+// getPaperTemplate 读取试卷模板，不存在时返回 nil
+func getPaperTemplate(ctx contractapi.TransactionContextInterface, templateID string) (*PaperTemplate, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(paperTemplateKeyType, []string{templateID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	templateJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if templateJSON == nil {
+		return nil, nil
+	}
+
+	var template PaperTemplate
+	if err := json.Unmarshal(templateJSON, &template); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal template: %v", err)
+	}
+	return &template, nil
+}
+
+// putPaperTemplate 写入试卷模板
+func putPaperTemplate(ctx contractapi.TransactionContextInterface, template *PaperTemplate) error {
+	key, err := ctx.GetStub().CreateCompositeKey(paperTemplateKeyType, []string{template.TemplateID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	templateJSON, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("failed to marshal template: %v", err)
+	}
+
+	return ctx.GetStub().PutState(key, templateJSON)
+}
+
+// ===================== 答案承诺 =====================
+
+// CommitmentReport 答案承诺批量核对结果
+type CommitmentReport struct {
+	ExamID         string   `json:"exam_id"`
+	Matched        []string `json:"matched"`
+	Mismatched     []string `json:"mismatched"`      // 链上承诺与提供值不一致
+	NoCommitment   []string `json:"no_commitment"`   // 链上尚未存储承诺
+	MissingEntries []string `json:"missing_entries"` // 考试中的试卷未出现在提供的映射中
+	UnknownPapers  []string `json:"unknown_papers"`  // 映射中的试卷不属于该考试
+}
+
+// AnswerKeyVersion 答案承诺的一个版本（只追加，不覆盖）
+type AnswerKeyVersion struct {
+	PaperID    string `json:"paper_id"`
+	Version    int    `json:"version"`
+	Commitment string `json:"commitment"`
+	RotatedBy  string `json:"rotated_by"`
+	RotatedAt  string `json:"rotated_at"`
+}
+
+// answerKeyVersionKeyType 答案承诺版本的复合键类型，键为 (paperID, 补零的版本号)
+const answerKeyVersionKeyType = "AnswerKeyVersion"
+
+// StoreAnswerKey 存储试卷答案的公开承诺；密钥轮换时追加新版本，最新版本即当前承诺
+// 已归档、已公开或冻结中的试卷不能再更换答案承诺
+func (c *ExamPaperContract) StoreAnswerKey(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	commitment string,
+	requesterID string,
+) error {
+	if err := requireRole(ctx, uploaderRoles...); err != nil {
+		return err
+	}
+	if commitment == "" {
+		return fmt.Errorf("commitment is required")
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if err := checkNotFrozen(paper); err != nil {
+		return err
+	}
+	if paper.Published {
+		return fmt.Errorf("paper %s is published, its answer key can no longer be changed", paperID)
+	}
+	if paper.Status == "archived" {
+		return fmt.Errorf("paper %s is archived, its answer key can no longer be changed", paperID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	paper.AnswerKeyVersion++
+	paper.AnswerKeyCommitment = commitment
+
+	versionJSON, err := json.Marshal(AnswerKeyVersion{
+		PaperID:    paperID,
+		Version:    paper.AnswerKeyVersion,
+		Commitment: commitment,
+		RotatedBy:  requesterID,
+		RotatedAt:  now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal answer key version: %v", err)
+	}
+	versionKey, err := ctx.GetStub().CreateCompositeKey(answerKeyVersionKeyType, []string{paperID, fmt.Sprintf("%06d", paper.AnswerKeyVersion)})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(versionKey, versionJSON); err != nil {
+		return fmt.Errorf("failed to put state: %v", err)
+	}
+
+	err = savePaper(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	return recordAccess(ctx, paperID, requesterID, "answer_key", "", "Answer key commitment stored")
+}
+
+// SetAnswerKeyUnlockTime 设置答案的独立发布时间（不早于试卷解锁时间和考试结束时间），为空表示与试卷同时发布
+func (c *ExamPaperContract) SetAnswerKeyUnlockTime(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	unlockTime string,
+) error {
+	if err := requireRole(ctx, uploaderRoles...); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+
+	if unlockTime != "" {
+		releaseTime, err := parseTime(unlockTime)
+		if err != nil {
+			return fmt.Errorf("failed to parse answer key unlock time: %v", err)
+		}
+		unlockTime = releaseTime.UTC().Format(time.RFC3339)
+	}
+
+	paper.AnswerKeyUnlockTime = unlockTime
+	if err := validatePaperTimeOrdering(paper); err != nil {
+		return err
+	}
+
+	return savePaper(ctx, paper)
+}
+
+// CheckAnswerKeyUnlockTime 检查答案是否已到发布时间（与试卷解锁时间相互独立）
+func (c *ExamPaperContract) CheckAnswerKeyUnlockTime(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (bool, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return false, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	released, _, err := answerKeyReleased(paper, now)
+	return released, err
+}
+
+// GetAnswerKey 获取当前（最新版本）的答案承诺，未到答案发布时间时拒绝
+func (c *ExamPaperContract) GetAnswerKey(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*AnswerKeyVersion, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	if paper.AnswerKeyCommitment == "" {
+		return nil, fmt.Errorf("paper %s has no answer key", paperID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	released, releaseAt, err := answerKeyReleased(paper, now)
+	if err != nil {
+		return nil, err
+	}
+	if !released {
+		return nil, fmt.Errorf("answer key for paper %s is sealed until %s", paperID, releaseAt)
+	}
+
+	visible, err := redactForCaller(ctx, paper)
+	if err != nil {
+		return nil, err
+	}
+	if visible.AnswerKeyCommitment == "" {
+		return nil, fmt.Errorf("caller is not allowed to view the answer key of paper %s", paperID)
+	}
+
+	// 版本日志之前存储的承诺没有版本记录
+	if paper.AnswerKeyVersion == 0 {
+		return &AnswerKeyVersion{PaperID: paperID, Commitment: paper.AnswerKeyCommitment}, nil
+	}
+
+	versionKey, err := ctx.GetStub().CreateCompositeKey(answerKeyVersionKeyType, []string{paperID, fmt.Sprintf("%06d", paper.AnswerKeyVersion)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	versionJSON, err := ctx.GetStub().GetState(versionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if versionJSON == nil {
+		return nil, fmt.Errorf("answer key version %d of paper %s not found", paper.AnswerKeyVersion, paperID)
+	}
+
+	var version AnswerKeyVersion
+	if err := json.Unmarshal(versionJSON, &version); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal answer key version: %v", err)
+	}
+	return &version, nil
+}
+
+// GetAnswerKeyVersions 获取试卷答案承诺的全部版本，按版本从旧到新排列
+// 调用者无权查看敏感字段或答案尚未发布时隐去每个版本的承诺值
+func (c *ExamPaperContract) GetAnswerKeyVersions(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]*AnswerKeyVersion, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	visible, err := redactForCaller(ctx, paper)
+	if err != nil {
+		return nil, err
+	}
+
+	// 与 redactForCaller 一致：已公开的试卷和管理员不受答案发布时间限制，发布时间无法解析时按未发布处理
+	sealed := false
+	if !paper.Published && requireRole(ctx, "admin") != nil {
+		now, err := getTxTime(ctx)
+		if err != nil {
+			return nil, err
+		}
+		released, _, err := answerKeyReleased(paper, now)
+		sealed = err != nil || !released
+	}
+	// 不在查看名单中的调用者看到的当前承诺已被隐去，历史版本同样隐去
+	redacted := sealed || visible.AnswerKeyCommitment == ""
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(answerKeyVersionKeyType, []string{paperID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get answer key versions: %v", err)
+	}
+	defer iterator.Close()
+
+	versions := []*AnswerKeyVersion{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var version AnswerKeyVersion
+		if err := json.Unmarshal(result.Value, &version); err != nil {
+			continue
+		}
+		if redacted {
+			version.Commitment = ""
+		}
+		versions = append(versions, &version)
+	}
+
+	return versions, nil
+}
+
+// VerifyAnswerKeyCommitments 批量核对考试中各试卷的答案承诺
+// commitmentsJSON 为 paperID -> commitment 的 JSON 对象；答案尚未发布或调用者不在查看名单中时，
+// 该试卷按 NoCommitment 报告，避免通过反复核对猜测未公开的承诺（管理员不受限制）
+func (c *ExamPaperContract) VerifyAnswerKeyCommitments(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+	commitmentsJSON string,
+) (*CommitmentReport, error) {
+	var commitments map[string]string
+	if err := json.Unmarshal([]byte(commitmentsJSON), &commitments); err != nil {
+		return nil, fmt.Errorf("invalid commitments: %v", err)
+	}
+
+	papers, err := getExamPapers(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CommitmentReport{
+		ExamID:         examID,
+		Matched:        []string{},
+		Mismatched:     []string{},
+		NoCommitment:   []string{},
+		MissingEntries: []string{},
+		UnknownPapers:  []string{},
+	}
+
+	inExam := make(map[string]bool)
+	for _, paper := range papers {
+		inExam[paper.PaperID] = true
+
+		visible, err := redactForCaller(ctx, paper)
+		if err != nil {
+			return nil, err
+		}
+
+		expected, ok := commitments[paper.PaperID]
+		switch {
+		case visible.AnswerKeyCommitment == "":
+			report.NoCommitment = append(report.NoCommitment, paper.PaperID)
+		case !ok:
+			report.MissingEntries = append(report.MissingEntries, paper.PaperID)
+		case expected == visible.AnswerKeyCommitment:
+			report.Matched = append(report.Matched, paper.PaperID)
+		default:
+			report.Mismatched = append(report.Mismatched, paper.PaperID)
+		}
+	}
+
+	for paperID := range commitments {
+		if !inExam[paperID] {
+			report.UnknownPapers = append(report.UnknownPapers, paperID)
+		}
+	}
+
+	// map 遍历顺序不确定，排序以保证各背书节点结果一致
+	sort.Strings(report.Matched)
+	sort.Strings(report.Mismatched)
+	sort.Strings(report.NoCommitment)
+	sort.Strings(report.MissingEntries)
+	sort.Strings(report.UnknownPapers)
+
+	return report, nil
+}
+
+// MetaIntegrityResult 元数据完整性校验结果
+type MetaIntegrityResult struct {
+	PaperID      string `json:"paper_id"`
+	StoredHash   string `json:"stored_hash"`
+	ComputedHash string `json:"computed_hash"`
+	Intact       bool   `json:"intact"`
+}
+
+// VerifyMetaIntegrity 重新计算不可变元数据指纹并与存储值比较，不一致说明记录被篡改
+func (c *ExamPaperContract) VerifyMetaIntegrity(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*MetaIntegrityResult, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	computed, err := computeMetaHash(paper)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetaIntegrityResult{
+		PaperID:      paperID,
+		StoredHash:   paper.MetaHash,
+		ComputedHash: computed,
+		Intact:       paper.MetaHash != "" && paper.MetaHash == computed,
+	}, nil
+}
+
+// VerifyDetachedSignature 用签名机构的公钥（PEM，支持 ECDSA/RSA/Ed25519）校验试卷的分离签名
+// 签名不匹配返回 false；试卷没有分离签名或公钥无效时返回错误
+func (c *ExamPaperContract) VerifyDetachedSignature(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	pubKeyPEM string,
+) (bool, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return false, err
+	}
+	if paper.DetachedSignature == nil {
+		return false, fmt.Errorf("paper %s has no detached signature", paperID)
+	}
+
+	block, _ := pem.Decode([]byte(pubKeyPEM))
+	if block == nil {
+		return false, fmt.Errorf("failed to decode public key PEM")
+	}
+	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse public key: %v", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(paper.DetachedSignature.Signature)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode signature: %v", err)
+	}
+
+	content, err := canonicalPaperContent(paper)
+	if err != nil {
+		return false, err
+	}
+	digest := sha256.Sum256(content)
+
+	switch key := pubKey.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest[:], signature), nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil, nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, content, signature), nil
+	default:
+		return false, fmt.Errorf("unsupported public key type %T", pubKey)
+	}
+}
+
+// MissingAnswerKey 缺少答案承诺的试卷
+type MissingAnswerKey struct {
+	PaperID string `json:"paper_id"`
+	Status  string `json:"status"`
+}
+
+// GetPapersMissingAnswerKey 获取考试中尚未存储答案承诺的试卷，locked 状态的排在前面
+func (c *ExamPaperContract) GetPapersMissingAnswerKey(
+	ctx contractapi.TransactionContextInterface,
+	examID string,
+) ([]*MissingAnswerKey, error) {
+	papers, err := getExamPapers(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []*MissingAnswerKey{}
+	for _, paper := range papers {
+		if paper.AnswerKeyCommitment == "" {
+			missing = append(missing, &MissingAnswerKey{PaperID: paper.PaperID, Status: paper.Status})
+		}
+	}
+
+	sort.Slice(missing, func(i, j int) bool {
+		li, lj := missing[i].Status == "locked", missing[j].Status == "locked"
+		if li != lj {
+			return li
+		}
+		return missing[i].PaperID < missing[j].PaperID
+	})
+
+	return missing, nil
+}
+
+// ===================== 关联试卷 =====================
+
+// PaperLink 试卷之间的关联（双向存储，两端各一条）
+type PaperLink struct {
+	PaperID      string `json:"paper_id"`
+	RelatedID    string `json:"related_id"`
+	RelationType string `json:"relation_type"` // 如 retake（补考）、makeup（缓考）
+	LinkedBy     string `json:"linked_by"`
+	LinkedAt     string `json:"linked_at"`
+}
+
+// paperLinkKeyType 试卷关联的复合键类型，键为 (paperID, relatedID)
+const paperLinkKeyType = "PaperLink"
+
+// LinkRelatedPaper 建立两份试卷之间的类型化双向关联（补考、缓考等），两份试卷均保持有效
+func (c *ExamPaperContract) LinkRelatedPaper(
+	ctx contractapi.TransactionContextInterface,
+	paperIDA string,
+	paperIDB string,
+	relationType string,
+) error {
+	if err := requireRole(ctx, uploaderRoles...); err != nil {
+		return err
+	}
+	if relationType == "" {
+		return fmt.Errorf("relationType is required")
+	}
+	if paperIDA == paperIDB {
+		return fmt.Errorf("cannot link paper %s to itself", paperIDA)
+	}
+
+	paperA, err := getPaper(ctx, paperIDA)
+	if err != nil {
+		return err
+	}
+	paperB, err := getPaper(ctx, paperIDB)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range paperA.RelatedPapers {
+		if id == paperIDB {
+			return fmt.Errorf("paper %s is already linked to %s", paperIDA, paperIDB)
+		}
+	}
+
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, pair := range [][2]*ExamPaper{{paperA, paperB}, {paperB, paperA}} {
+		paper, related := pair[0], pair[1]
+
+		linkJSON, err := json.Marshal(PaperLink{
+			PaperID:      paper.PaperID,
+			RelatedID:    related.PaperID,
+			RelationType: relationType,
+			LinkedBy:     userID,
+			LinkedAt:     now.Format(time.RFC3339),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal link: %v", err)
+		}
+		linkKey, err := ctx.GetStub().CreateCompositeKey(paperLinkKeyType, []string{paper.PaperID, related.PaperID})
+		if err != nil {
+			return fmt.Errorf("failed to create composite key: %v", err)
+		}
+		if err := ctx.GetStub().PutState(linkKey, linkJSON); err != nil {
+			return fmt.Errorf("failed to put state: %v", err)
+		}
+
+		paper.RelatedPapers = append(paper.RelatedPapers, related.PaperID)
+		if err := savePaper(ctx, paper); err != nil {
+			return err
+		}
+
+		err = recordAccess(ctx, paper.PaperID, userID, "link", "", fmt.Sprintf("Linked to %s as %s", related.PaperID, relationType))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetRelatedPapers 获取试卷的全部关联及其类型
+func (c *ExamPaperContract) GetRelatedPapers(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]*PaperLink, error) {
+	if _, err := getPaper(ctx, paperID); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(paperLinkKeyType, []string{paperID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get links: %v", err)
+	}
+	defer iterator.Close()
+
+	links := []*PaperLink{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var link PaperLink
+		if err := json.Unmarshal(result.Value, &link); err != nil {
+			continue
+		}
+		links = append(links, &link)
+	}
+
+	return links, nil
+}
+
+// repointPaperLinks 试卷改名时更新各关联试卷一端指向它的关联记录和 RelatedPapers
+// 本端的关联记录以试卷ID为首个键属性，由 movePaperScopedKeys 迁移
+func repointPaperLinks(ctx contractapi.TransactionContextInterface, paper *ExamPaper, newID string) error {
+	stub := ctx.GetStub()
+	for _, relatedID := range paper.RelatedPapers {
+		oldKey, err := stub.CreateCompositeKey(paperLinkKeyType, []string{relatedID, paper.PaperID})
+		if err != nil {
+			return fmt.Errorf("failed to create composite key: %v", err)
+		}
+		linkJSON, err := stub.GetState(oldKey)
+		if err != nil {
+			return fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if linkJSON != nil {
+			var link PaperLink
+			if err := json.Unmarshal(linkJSON, &link); err != nil {
+				return fmt.Errorf("failed to unmarshal link: %v", err)
+			}
+			link.RelatedID = newID
+
+			updated, err := json.Marshal(link)
+			if err != nil {
+				return fmt.Errorf("failed to marshal link: %v", err)
+			}
+			newKey, err := stub.CreateCompositeKey(paperLinkKeyType, []string{relatedID, newID})
+			if err != nil {
+				return fmt.Errorf("failed to create composite key: %v", err)
+			}
+			if err := stub.PutState(newKey, updated); err != nil {
+				return fmt.Errorf("failed to put state: %v", err)
+			}
+			if err := stub.DelState(oldKey); err != nil {
+				return fmt.Errorf("failed to delete state: %v", err)
+			}
+		}
+
+		related, err := getPaper(ctx, relatedID)
+		if err != nil {
+			return err
+		}
+		for i, id := range related.RelatedPapers {
+			if id == paper.PaperID {
+				related.RelatedPapers[i] = newID
+			}
+		}
+		if err := savePaper(ctx, related); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ===================== 答卷提交 =====================
+
+// Submission 学生答卷提交记录
+type Submission struct {
+	PaperID        string `json:"paper_id"`
+	StudentID      string `json:"student_id"`
+	SubmissionHash string `json:"submission_hash"` // 答卷文件哈希
+	IPFSHash       string `json:"ipfs_hash"`       // 答卷文件的IPFS哈希
+	SubmittedAt    string `json:"submitted_at"`
+	Attempt        int    `json:"attempt"` // 第几次提交，允许重新提交时递增
+}
+
+// submissionKeyType 答卷提交的复合键类型，键为 (paperID, studentID)
+const submissionKeyType = "Submission"
+
+// RecordSubmission 记录学生答卷的哈希，仅在试卷已解锁且处于考试窗口内时允许
+// 学生只能以自己的身份提交；重复提交默认拒绝，配置 AllowResubmission 后覆盖并递增 Attempt
+func (c *ExamPaperContract) RecordSubmission(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	studentID string,
+	submissionHash string,
+	ipfsHash string,
+) error {
+	if err := requireRole(ctx, "student", "superintendent", "admin"); err != nil {
+		return err
+	}
+	if studentID == "" || submissionHash == "" {
+		return fmt.Errorf("studentID and submissionHash are required")
+	}
+	if err := validateIPFSHash(ipfsHash); err != nil {
+		return err
+	}
+
+	if requireRole(ctx, "student") == nil {
+		callerID, err := callerUserID(ctx)
+		if err != nil {
+			return err
+		}
+		if callerID != studentID {
+			return fmt.Errorf("students can only submit as themselves")
+		}
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if paper.Status != "unlocked" {
+		return fmt.Errorf("paper %s is %s, submissions require an unlocked paper", paperID, paper.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	state, err := examWindowState(paper, now)
+	if err != nil {
+		return err
+	}
+	if state != windowOpen {
+		return fmt.Errorf("paper %s is not within its exam window", paperID)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(submissionKeyType, []string{paperID, studentID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	attempt := 1
+	existingJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existingJSON != nil {
+		config, err := getConfig(ctx)
+		if err != nil {
+			return err
+		}
+		if !config.AllowResubmission {
+			return fmt.Errorf("student %s has already submitted for paper %s", studentID, paperID)
+		}
+
+		var existing Submission
+		if err := json.Unmarshal(existingJSON, &existing); err != nil {
+			return fmt.Errorf("failed to unmarshal submission: %v", err)
+		}
+		attempt = existing.Attempt + 1
+	}
+
+	submissionJSON, err := json.Marshal(Submission{
+		PaperID:        paperID,
+		StudentID:      studentID,
+		SubmissionHash: submissionHash,
+		IPFSHash:       ipfsHash,
+		SubmittedAt:    now.Format(time.RFC3339),
+		Attempt:        attempt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal submission: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, submissionJSON); err != nil {
+		return fmt.Errorf("failed to put state: %v", err)
+	}
+
+	return recordAccess(ctx, paperID, studentID, "submit", "", fmt.Sprintf("Answers submitted (attempt %d)", attempt))
+}
+
+// GetSubmissions 获取试卷的全部答卷提交记录（按学生ID排序）
+func (c *ExamPaperContract) GetSubmissions(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]*Submission, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(submissionKeyType, []string{paperID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get submissions: %v", err)
+	}
+	defer iterator.Close()
+
+	submissions := []*Submission{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var submission Submission
+		if err := json.Unmarshal(result.Value, &submission); err != nil {
+			continue
+		}
+		submissions = append(submissions, &submission)
+	}
+
+	return submissions, nil
+}
+
+// VerifySubmission 核对学生答卷的哈希是否与链上记录一致
+func (c *ExamPaperContract) VerifySubmission(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	studentID string,
+	providedHash string,
+) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(submissionKeyType, []string{paperID, studentID})
+	if err != nil {
+		return false, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	submissionJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if submissionJSON == nil {
+		return false, fmt.Errorf("no submission from student %s for paper %s", studentID, paperID)
+	}
+
+	var submission Submission
+	if err := json.Unmarshal(submissionJSON, &submission); err != nil {
+		return false, fmt.Errorf("failed to unmarshal submission: %v", err)
+	}
+
+	return submission.SubmissionHash == providedHash, nil
+}
+
+// ===================== 阅卷 =====================
+
+// GradingInfo 试卷的阅卷信息
+type GradingInfo struct {
+	PaperID     string `json:"paper_id"`
+	Graded      bool   `json:"graded"`
+	GradedAt    string `json:"graded_at,omitempty"`
+	GradedBy    string `json:"graded_by,omitempty"`
+	ResultsHash string `json:"results_hash,omitempty"`
+}
+
+// MarkGraded 记录阅卷完成：试卷须已归档且考试窗口已结束，resultsHash 为成绩文件的IPFS哈希
+func (c *ExamPaperContract) MarkGraded(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	gradedBy string,
+	resultsHash string,
+) error {
+	if err := requireRole(ctx, "admin", "coe", "teacher"); err != nil {
+		return err
+	}
+	if gradedBy == "" {
+		return fmt.Errorf("gradedBy is required")
+	}
+	if err := validateIPFSHash(resultsHash); err != nil {
+		return fmt.Errorf("invalid results hash: %v", err)
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+	if paper.Status != "archived" {
+		return fmt.Errorf("paper %s is %s, only archived papers can be graded", paperID, paper.Status)
+	}
+	if paper.GradedAt != "" {
+		return fmt.Errorf("paper %s was already graded at %s", paperID, paper.GradedAt)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	_, end, err := examWindowBounds(paper)
+	if err != nil {
+		return err
+	}
+	if !end.IsZero() && now.Before(end) {
+		return fmt.Errorf("exam window of paper %s ends at %s, grading is not allowed before then", paperID, examEndTimeString(paper))
+	}
+
+	paper.GradedAt = now.Format(time.RFC3339)
+	paper.GradedBy = gradedBy
+	paper.ResultsHash = resultsHash
+
+	err = savePaper(ctx, paper)
+	if err != nil {
+		return err
+	}
+
+	return recordAccess(ctx, paperID, gradedBy, "graded", "", fmt.Sprintf("Grading completed, results %s", resultsHash))
+}
+
+// GetGradingInfo 获取试卷的阅卷信息
+func (c *ExamPaperContract) GetGradingInfo(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (*GradingInfo, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GradingInfo{
+		PaperID:     paper.PaperID,
+		Graded:      paper.GradedAt != "",
+		GradedAt:    paper.GradedAt,
+		GradedBy:    paper.GradedBy,
+		ResultsHash: paper.ResultsHash,
+	}, nil
+}
+
+// ===================== 考场事件 =====================
+
+// Incident 考试期间与试卷相关的事件记录（作弊嫌疑、技术故障等），记录后不可修改
+type Incident struct {
+	IncidentID   string `json:"incident_id"`
+	PaperID      string `json:"paper_id"`
+	ProctorID    string `json:"proctor_id"`
+	IncidentType string `json:"incident_type"`
+	Description  string `json:"description"`
+	RecordedAt   string `json:"recorded_at"`
+}
+
+// incidentKeyType 考场事件的复合键类型，键为 (paperID, incidentID)
+const incidentKeyType = "Incident"
+
+// incidentTypes 允许的事件类型
+var incidentTypes = map[string]bool{
+	"cheating_suspicion": true,
+	"technical_failure":  true,
+	"disruption":         true,
+	"medical":            true,
+	"other":              true,
+}
+
+// RecordIncident 记录考场事件（监考或管理员），返回事件ID
+func (c *ExamPaperContract) RecordIncident(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	proctorID string,
+	incidentType string,
+	description string,
+) (string, error) {
+	if err := requireRole(ctx, "superintendent", "admin"); err != nil {
+		return "", err
+	}
+	if proctorID == "" || description == "" {
+		return "", fmt.Errorf("proctorID and description are required")
+	}
+	if !incidentTypes[incidentType] {
+		return "", fmt.Errorf("unknown incident type %s", incidentType)
+	}
+
+	if _, err := getPaper(ctx, paperID); err != nil {
+		return "", err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	incident := Incident{
+		IncidentID:   fmt.Sprintf("INC_%s", ctx.GetStub().GetTxID()[:16]),
+		PaperID:      paperID,
+		ProctorID:    proctorID,
+		IncidentType: incidentType,
+		Description:  description,
+		RecordedAt:   now.Format(time.RFC3339),
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(incidentKeyType, []string{paperID, incident.IncidentID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	// 事件不可修改：同一键已存在时拒绝覆盖
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return "", fmt.Errorf("incident %s already exists", incident.IncidentID)
+	}
+
+	incidentJSON, err := json.Marshal(incident)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal incident: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, incidentJSON); err != nil {
+		return "", fmt.Errorf("failed to put state: %v", err)
+	}
+
+	return incident.IncidentID, nil
+}
+
+// GetIncidents 获取试卷的全部考场事件，按记录时间排序
+func (c *ExamPaperContract) GetIncidents(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]*Incident, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(incidentKeyType, []string{paperID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incidents: %v", err)
+	}
+	defer iterator.Close()
+
+	incidents := []*Incident{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var incident Incident
+		if err := json.Unmarshal(result.Value, &incident); err != nil {
+			continue
+		}
+		incidents = append(incidents, &incident)
+	}
+	sort.SliceStable(incidents, func(i, j int) bool { return incidents[i].RecordedAt < incidents[j].RecordedAt })
+
+	return incidents, nil
+}
+
+// ===================== 运维备注 =====================
+
+// PaperNote 试卷的运维备注（如网关故障后重新上传），只追加、不可修改
+type PaperNote struct {
+	NoteID    string `json:"note_id"`
+	PaperID   string `json:"paper_id"`
+	AuthorID  string `json:"author_id"`
+	Note      string `json:"note"`
+	CreatedAt string `json:"created_at"`
+}
+
+// paperNoteKeyType 运维备注的复合键类型，键为 (paperID, noteID)
+const paperNoteKeyType = "PaperNote"
+
+// maxNoteLength 单条备注的最大长度（字符数）
+const maxNoteLength = 1000
+
+// AddPaperNote 为试卷追加一条运维备注（仅管理员），返回备注ID
+func (c *ExamPaperContract) AddPaperNote(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	note string,
+	authorID string,
+) (string, error) {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return "", err
+	}
+	if authorID == "" || strings.TrimSpace(note) == "" {
+		return "", fmt.Errorf("authorID and note are required")
+	}
+	if length := len([]rune(note)); length > maxNoteLength {
+		return "", fmt.Errorf("note is too long: %d characters (max %d)", length, maxNoteLength)
+	}
+
+	if _, err := getPaper(ctx, paperID); err != nil {
+		return "", err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	paperNote := PaperNote{
+		NoteID:    fmt.Sprintf("NOTE_%s", ctx.GetStub().GetTxID()[:16]),
+		PaperID:   paperID,
+		AuthorID:  authorID,
+		Note:      note,
+		CreatedAt: now.Format(time.RFC3339),
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(paperNoteKeyType, []string{paperID, paperNote.NoteID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	// 备注不可修改：同一键已存在时拒绝覆盖
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return "", fmt.Errorf("note %s already exists", paperNote.NoteID)
+	}
+
+	noteJSON, err := json.Marshal(paperNote)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal note: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, noteJSON); err != nil {
+		return "", fmt.Errorf("failed to put state: %v", err)
+	}
+
+	return paperNote.NoteID, nil
+}
+
+// GetPaperNotes 获取试卷的全部运维备注，按时间排序
+func (c *ExamPaperContract) GetPaperNotes(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]*PaperNote, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(paperNoteKeyType, []string{paperID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notes: %v", err)
+	}
+	defer iterator.Close()
+
+	notes := []*PaperNote{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var note PaperNote
+		if err := json.Unmarshal(result.Value, &note); err != nil {
+			continue
+		}
+		notes = append(notes, &note)
+	}
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].CreatedAt < notes[j].CreatedAt })
+
+	return notes, nil
+}
+
+// ===================== 查看名单 =====================
+
+// AddViewer 将用户加入试卷的查看名单（仅管理员）
+func (c *ExamPaperContract) AddViewer(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	userID string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+	if userID == "" {
+		return fmt.Errorf("userID is required")
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range paper.AllowedViewers {
+		if v == userID {
+			return fmt.Errorf("user %s is already a viewer of paper %s", userID, paperID)
+		}
+	}
+	paper.AllowedViewers = append(paper.AllowedViewers, userID)
+
+	return savePaper(ctx, paper)
+}
+
+// RemoveViewer 将用户移出试卷的查看名单（仅管理员）
+func (c *ExamPaperContract) RemoveViewer(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	userID string,
+) error {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+
+	viewers := []string{}
+	for _, v := range paper.AllowedViewers {
+		if v != userID {
+			viewers = append(viewers, v)
+		}
+	}
+	if len(viewers) == len(paper.AllowedViewers) {
+		return fmt.Errorf("user %s is not a viewer of paper %s", userID, paperID)
+	}
+	paper.AllowedViewers = viewers
+
+	return savePaper(ctx, paper)
+}
+
+// ===================== 分块校验 =====================
+
+// VerifyChunk 使用 Merkle 证明校验单个分块
+// chunkHash 为分块的 SHA-256 哈希（叶子），proofJSON 为自叶子向上的兄弟节点哈希数组 (hex)
+// 父节点 = SHA-256(左 || 右)，chunkIndex 的各二进制位决定每层的左右顺序
+func (c *ExamPaperContract) VerifyChunk(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	chunkIndex int,
+	chunkHash string,
+	proofJSON string,
+) (bool, error) {
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return false, err
+	}
+	if paper.MerkleRoot == "" {
+		return false, fmt.Errorf("paper %s has no merkle root", paperID)
+	}
+	if chunkIndex < 0 {
+		return false, fmt.Errorf("chunkIndex must not be negative")
+	}
+
+	var proof []string
+	if err := json.Unmarshal([]byte(proofJSON), &proof); err != nil {
+		return false, fmt.Errorf("invalid proof: %v", err)
+	}
+
+	node, err := hex.DecodeString(chunkHash)
+	if err != nil {
+		return false, fmt.Errorf("invalid chunk hash: must be hex encoded")
+	}
+
+	index := chunkIndex
+	for _, siblingHex := range proof {
+		sibling, err := hex.DecodeString(siblingHex)
+		if err != nil {
+			return false, fmt.Errorf("invalid proof entry %s: must be hex encoded", siblingHex)
+		}
+
+		var sum [32]byte
+		if index%2 == 0 {
+			sum = sha256.Sum256(append(append([]byte{}, node...), sibling...))
+		} else {
+			sum = sha256.Sum256(append(append([]byte{}, sibling...), node...))
+		}
+		node = sum[:]
+		index /= 2
+	}
+
+	return hex.EncodeToString(node) == paper.MerkleRoot, nil
+}
+
+// ===================== 副本管理 =====================
+
+// AddReplica 为试卷添加一个IPFS副本CID
+func (c *ExamPaperContract) AddReplica(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	cid string,
+) error {
+	if err := requireRole(ctx, uploaderRoles...); err != nil {
+		return err
+	}
+	if err := validateIPFSHash(cid); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+
+	if cid == paper.IPFSHash {
+		return fmt.Errorf("replica %s is the primary IPFS hash", cid)
+	}
+	for _, r := range paper.Replicas {
+		if r == cid {
+			return fmt.Errorf("replica %s already exists", cid)
+		}
+	}
+
+	paper.Replicas = append(paper.Replicas, cid)
+
+	return savePaper(ctx, paper)
+}
+
+// GetReplicas 获取试卷的副本CID列表
+func (c *ExamPaperContract) GetReplicas(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) ([]string, error) {
+	paper, err := c.GetPaper(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := paper.Replicas
+	if replicas == nil {
+		replicas = []string{}
+	}
+	return replicas, nil
+}
+
+// ===================== 诊断 =====================
+
+// GetPaperIndexEntries 列出引用试卷的全部复合键（日志等试卷下的键、各二级索引条目），用于确认删除是否清理干净
+// 键以属性用 "/" 连接的形式返回；二级索引需全量扫描，试卷已删除时同样可用（仅管理员，只读）
+func (c *ExamPaperContract) GetPaperIndexEntries(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+) (map[string][]string, error) {
+	if err := requireRole(ctx, "admin"); err != nil {
+		return nil, err
+	}
+	if paperID == "" {
+		return nil, fmt.Errorf("paperID is required")
+	}
+
+	entries := make(map[string][]string)
+
+	for _, objectType := range paperScopedKeyTypes {
+		keys, err := findCompositeKeys(ctx, objectType, []string{paperID}, func(attrs []string) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+		entries[objectType] = keys
+	}
+
+	// 二级索引的键为 name(value, paperID)，试卷ID位于最后一个属性
+	for _, index := range paperIndexes {
+		keys, err := findCompositeKeys(ctx, index.name, []string{}, func(attrs []string) bool {
+			return len(attrs) > 0 && attrs[len(attrs)-1] == paperID
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries[index.name] = keys
+	}
+
+	return entries, nil
+}
+
+// findCompositeKeys 按前缀遍历复合键，返回满足条件的键（属性用 "/" 连接）
+func findCompositeKeys(
+	ctx contractapi.TransactionContextInterface,
+	objectType string,
+	prefix []string,
+	match func(attrs []string) bool,
+) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s entries: %v", objectType, err)
+	}
+	defer iterator.Close()
+
+	keys := []string{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(result.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+		if match(attrs) {
+			keys = append(keys, strings.Join(attrs, "/"))
+		}
+	}
+
+	return keys, nil
+}
+
+// ===================== 内容可用性探测 =====================
+
+// 探测结果
+const (
+	probeAvailable   = "available"
+	probeUnavailable = "unavailable"
+)
+
+// RecordProbe 记录链下探测程序对试卷 IPFS 内容可用性的探测结果（仅 prober 角色）
+func (c *ExamPaperContract) RecordProbe(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	available bool,
+) error {
+	if err := requireRole(ctx, "prober"); err != nil {
+		return err
+	}
+
+	paper, err := getPaper(ctx, paperID)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	paper.LastProbedAt = now.Format(time.RFC3339)
+	paper.ProbeStatus = probeUnavailable
+	if available {
+		paper.ProbeStatus = probeAvailable
+	}
+
+	if err := savePaper(ctx, paper); err != nil {
+		return err
+	}
+
+	proberID, err := callerUserID(ctx)
+	if err != nil {
+		return err
+	}
+	return recordAccess(ctx, paperID, proberID, "probe", "", fmt.Sprintf("IPFS content %s", paper.ProbeStatus))
+}
+
+// GetUnavailablePapers 获取最近一次探测结果为不可用的试卷
+func (c *ExamPaperContract) GetUnavailablePapers(
+	ctx contractapi.TransactionContextInterface,
+) ([]*ExamPaper, error) {
+	return queryVisiblePapers(ctx, map[string]interface{}{"probe_status": probeUnavailable})
+}
+
+// ===================== 辅助函数 =====================
+
+// systemKeyPrefix 系统单例键的保留前缀，试卷ID不得使用
+const systemKeyPrefix = "SYS_"
+
+// configKey 链码配置在账本中的键
+const configKey = systemKeyPrefix + "ChaincodeConfig"
+
+// legacyConfigKey 加入保留前缀之前的配置键，读取时兼容，写入时迁移
+const legacyConfigKey = "ChaincodeConfig"
+
+// reservedPaperIDs 与系统键冲突、不能作为试卷ID的单例键
+var reservedPaperIDs = map[string]bool{
+	legacyConfigKey: true,
+}
+
+// checkReservedPaperID 拒绝与系统键冲突的试卷ID
+func checkReservedPaperID(paperID string) error {
+	if strings.HasPrefix(paperID, systemKeyPrefix) {
+		return fmt.Errorf("paperID %s uses the reserved prefix %s", paperID, systemKeyPrefix)
+	}
+	if reservedPaperIDs[paperID] {
+		return fmt.Errorf("paperID %s is a reserved system key", paperID)
+	}
+	return nil
+}
+
+// getConfig 读取链码配置，未设置时返回默认值
+func getConfig(ctx contractapi.TransactionContextInterface) (*ChaincodeConfig, error) {
+	configJSON, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	if configJSON == nil {
+		configJSON, err = ctx.GetStub().GetState(legacyConfigKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %v", err)
+		}
+	}
+
+	config := &ChaincodeConfig{}
+	if configJSON == nil {
+		return config, nil
+	}
+
+	err = json.Unmarshal(configJSON, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %v", err)
+	}
+	return config, nil
+}
+
+// putConfig 写入链码配置
+func putConfig(ctx contractapi.TransactionContextInterface, config *ChaincodeConfig) error {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(configKey, configJSON); err != nil {
+		return err
+	}
+	return ctx.GetStub().DelState(legacyConfigKey)
+}
+
+// getExam 读取考试登记，不存在时返回 nil
+func getExam(ctx contractapi.TransactionContextInterface, examID string) (*Exam, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("Exam", []string{examID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	examJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if examJSON == nil {
+		return nil, nil
+	}
+
+	var exam Exam
+	err = json.Unmarshal(examJSON, &exam)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal exam: %v", err)
+	}
+	return &exam, nil
+}
+
+// putExam 写入考试登记
+func putExam(ctx contractapi.TransactionContextInterface, exam *Exam) error {
+	key, err := ctx.GetStub().CreateCompositeKey("Exam", []string{exam.ExamID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	examJSON, err := json.Marshal(exam)
+	if err != nil {
+		return fmt.Errorf("failed to marshal exam: %v", err)
+	}
+
+	return ctx.GetStub().PutState(key, examJSON)
+}
+
+// checkExamRegistered 严格模式下校验考试已登记，非严格模式不做检查
+func checkExamRegistered(ctx contractapi.TransactionContextInterface, examID string) error {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !config.StrictExamRegistry {
+		return nil
+	}
+
+	exam, err := getExam(ctx, examID)
+	if err != nil {
+		return err
+	}
+	if exam == nil {
+		return fmt.Errorf("exam %s is not registered", examID)
+	}
+	return nil
+}
+
+// paperIDPatternCache 缓存最近一次编译的试卷ID正则，避免每个交易重复编译
+var paperIDPatternCache struct {
+	sync.Mutex
+	pattern string
+	re      *regexp.Regexp
+}
+
+// compilePaperIDPattern 编译试卷ID正则（带缓存）
+func compilePaperIDPattern(pattern string) (*regexp.Regexp, error) {
+	paperIDPatternCache.Lock()
+	defer paperIDPatternCache.Unlock()
+
+	if paperIDPatternCache.re != nil && paperIDPatternCache.pattern == pattern {
+		return paperIDPatternCache.re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	paperIDPatternCache.pattern = pattern
+	paperIDPatternCache.re = re
+	return re, nil
+}
+
+// validatePaperID 校验 paperID 不与系统键冲突，配置了试卷ID正则时校验格式
+func validatePaperID(ctx contractapi.TransactionContextInterface, paperID string) error {
+	if err := checkReservedPaperID(paperID); err != nil {
+		return err
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if config.PaperIDPattern == "" {
+		return nil
+	}
+
+	re, err := compilePaperIDPattern(config.PaperIDPattern)
+	if err != nil {
+		return fmt.Errorf("invalid paperID pattern: %v", err)
+	}
+	if !re.MatchString(paperID) {
+		return fmt.Errorf("paperID %s does not match required pattern %s", paperID, config.PaperIDPattern)
+	}
+	return nil
+}
+
+// checkUnlockLeadTime 配置了最短提前量时，检查解锁时间不早于交易时间加提前量
+func checkUnlockLeadTime(ctx contractapi.TransactionContextInterface, unlockTime string) error {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if config.MinLeadTimeSeconds == 0 {
+		return nil
+	}
+
+	unlock, err := parseTime(unlockTime)
+	if err != nil {
+		return fmt.Errorf("failed to parse unlock time: %v", err)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	earliest := now.Add(time.Duration(config.MinLeadTimeSeconds) * time.Second)
+	if unlock.Before(earliest) {
+		return fmt.Errorf("unlock time must be at least %d seconds after the transaction time (earliest %s)",
+			config.MinLeadTimeSeconds, earliest.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// getTxTime 获取交易时间戳（各背书节点一致）
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get tx timestamp: %v", err)
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+// requireRole 校验调用者证书中的 role 属性
+func requireRole(ctx contractapi.TransactionContextInterface, roles ...string) error {
+	role, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
+	if err != nil {
+		return fmt.Errorf("failed to read client identity: %v", err)
+	}
+	if found {
+		for _, r := range roles {
+			if r == role {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("caller role %q is not permitted, requires one of %v", role, roles)
+}
+
+// 考试窗口状态
+const (
+	windowPending = "pending" // 未到解锁时间
+	windowOpen    = "open"    // 处于 [UnlockTime, ExamEndTime) 内
+	windowClosed  = "closed"  // 已过考试结束时间（AccessClosed），拒绝解锁和查看
+)
+
+// validateTimeOrdering 校验试卷各时间字段的先后关系，为空的字段不参与校验：
+// unlock_time 早于 exam_end_time；answer_key_unlock_time 不早于 exam_end_time（考试结束后才发布答案），也不早于 unlock_time
+func validateTimeOrdering(unlockTime string, examEndTime string, answerKeyUnlockTime string) error {
+	names := []string{"unlock_time", "exam_end_time", "answer_key_unlock_time"}
+	values := []string{unlockTime, examEndTime, answerKeyUnlockTime}
+
+	parsed := make([]time.Time, len(values))
+	for i, value := range values {
+		if value == "" {
+			continue
+		}
+		t, err := parseTime(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", names[i], err)
+		}
+		parsed[i] = t
+	}
+	unlock, end, answerKey := parsed[0], parsed[1], parsed[2]
+
+	if !unlock.IsZero() && !end.IsZero() && !unlock.Before(end) {
+		return fmt.Errorf("unlock_time %s must be before exam_end_time %s", unlockTime, examEndTime)
+	}
+	if !answerKey.IsZero() && !end.IsZero() && answerKey.Before(end) {
+		return fmt.Errorf("answer_key_unlock_time %s must not be before exam_end_time %s", answerKeyUnlockTime, examEndTime)
+	}
+	if !answerKey.IsZero() && !unlock.IsZero() && answerKey.Before(unlock) {
+		return fmt.Errorf("answer_key_unlock_time %s must not be before unlock_time %s", answerKeyUnlockTime, unlockTime)
+	}
+	return nil
+}
+
+// validatePaperTimeOrdering 按试卷当前的解锁时间、考试结束时间（含按时长推算的）和答案发布时间校验先后关系
+func validatePaperTimeOrdering(paper *ExamPaper) error {
+	return validateTimeOrdering(paper.UnlockTime, examEndTimeString(paper), paper.AnswerKeyUnlockTime)
+}
+
+// acceptedTimeLayouts parseTime 依次尝试的时间格式；不含时区偏移的格式按 UTC 解释
+var acceptedTimeLayouts = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// parseTime 宽松解析客户端提交的时间（RFC3339 及常见变体），结果统一为 UTC
+func parseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range acceptedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse time %q, accepted formats: %s", value, strings.Join(acceptedTimeLayouts, ", "))
+}
+
+// examWindowState 计算试卷在给定时间所处的考试窗口状态，未设置结束时间时窗口不关闭
+func examWindowState(paper *ExamPaper, now time.Time) (string, error) {
+	start, end, err := examWindowBounds(paper)
+	if err != nil {
+		return "", err
+	}
+	if now.Before(start) {
+		return windowPending, nil
+	}
+	if !end.IsZero() && !now.Before(end) {
+		return windowClosed, nil
+	}
+	return windowOpen, nil
+}
+
+// examWindowBounds 计算考试窗口的起止时间：结束时间取 ExamEndTime，
+// 未设置时取 UnlockTime + DurationMinutes，两者都未设置时结束时间为零值（窗口不关闭）
+func examWindowBounds(paper *ExamPaper) (time.Time, time.Time, error) {
+	start, err := parseTime(paper.UnlockTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to parse unlock time: %v", err)
+	}
+
+	var end time.Time
+	switch {
+	case paper.ExamEndTime != "":
+		end, err = parseTime(paper.ExamEndTime)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("failed to parse exam end time: %v", err)
+		}
+	case paper.DurationMinutes > 0:
+		end = start.Add(time.Duration(paper.DurationMinutes) * time.Minute)
+	}
+
+	return start, end, nil
+}
+
+// examEndTimeString 返回试卷生效的考试结束时间 (UTC RFC3339)，没有结束时间或无法解析时返回空字符串
+func examEndTimeString(paper *ExamPaper) string {
+	_, end, err := examWindowBounds(paper)
+	if err != nil || end.IsZero() {
+		return ""
+	}
+	return end.UTC().Format(time.RFC3339)
+}
+
+// unlockRoles 可解锁试卷的角色
+var unlockRoles = []string{"admin", "coe", "superintendent"}
+
+// unlockBlockReason 检查 UnlockPaper 的全部前置条件，返回阻止解锁的原因（为空表示允许）
+// 读取状态失败等系统错误通过 error 返回
+func unlockBlockReason(ctx contractapi.TransactionContextInterface, paper *ExamPaper) (string, error) {
+	if err := requireRole(ctx, unlockRoles...); err != nil {
+		return err.Error(), nil
+	}
+
+	wait, err := unlockRetryWait(ctx, paper)
+	if err != nil {
+		return "", err
+	}
+	if wait != "" {
+		return wait, nil
+	}
+
+	return unlockGateReason(ctx, paper)
+}
+
+// unlockGateReason 检查与调用者无关的解锁条件（冻结、暂扣、状态、考试窗口、审批和并发上限），
+// 返回阻止解锁的原因（为空表示允许）；调用者角色和重试退避由 unlockBlockReason 检查
+func unlockGateReason(ctx contractapi.TransactionContextInterface, paper *ExamPaper) (string, error) {
+	if err := checkNotFrozen(paper); err != nil {
+		return err.Error(), nil
+	}
+
+	if paper.HoldReason != "" {
+		return fmt.Sprintf("paper %s is on hold: %s", paper.PaperID, paper.HoldReason), nil
+	}
+
+	if paper.Status != "locked" {
+		return fmt.Sprintf("paper %s is %s, only locked papers can be unlocked", paper.PaperID, paper.Status), nil
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	state, err := examWindowState(paper, now)
+	if err != nil {
+		return "", err
+	}
+	switch state {
+	case windowPending:
+		return fmt.Sprintf("paper cannot be unlocked until %s", paper.UnlockTime), nil
+	case windowClosed:
+		return fmt.Sprintf("access to paper %s closed at %s", paper.PaperID, examEndTimeString(paper)), nil
+	}
+
+	// 多人、多组织审批
+	shortfall, err := approvalShortfall(ctx, paper)
+	if err != nil || shortfall != "" {
+		return shortfall, err
+	}
+
+	remaining, limited, err := examUnlockCapacity(ctx, paper.ExamID)
+	if err != nil {
+		return "", err
+	}
+	if limited && remaining <= 0 {
+		return fmt.Sprintf("exam %s unlock capacity reached, try later", paper.ExamID), nil
+	}
+	return "", nil
+}
+
+// examUnlockCapacity 返回考试还可以解锁的试卷数；limited 为 false 表示考试未设置并发解锁上限
+// 已解锁数按 exam_id 查询统计，试卷归档或重新锁定后自动释放名额
+func examUnlockCapacity(ctx contractapi.TransactionContextInterface, examID string) (int, bool, error) {
+	exam, err := getExam(ctx, examID)
+	if err != nil {
+		return 0, false, err
+	}
+	if exam == nil || exam.MaxConcurrentUnlocked <= 0 {
+		return 0, false, nil
+	}
+
+	unlocked, err := queryPapers(ctx, map[string]interface{}{"exam_id": examID, "status": "unlocked"})
+	if err != nil {
+		return 0, false, err
+	}
+	return exam.MaxConcurrentUnlocked - len(unlocked), true, nil
+}
+
+// approvalShortfall 检查有效审批是否满足人数和组织数要求，返回不满足的原因（为空表示满足）
+// 同一组织的多个审批人只计一次；不满足组织数要求时列出已审批和尚未审批的组织
+func approvalShortfall(ctx contractapi.TransactionContextInterface, paper *ExamPaper) (string, error) {
+	approvals, err := getUnlockApprovals(ctx, paper.PaperID)
+	if err != nil {
+		return "", err
+	}
+
+	count := 0
+	approvedOrgs := make(map[string]bool)
+	for _, approval := range approvals {
+		valid, err := isApprovalValid(ctx, approval)
+		if err != nil {
+			return "", err
+		}
+		if !valid {
+			continue
+		}
+		count++
+		if approval.ApproverMSP != "" {
+			approvedOrgs[approval.ApproverMSP] = true
+		}
+	}
+
+	if count < paper.RequiredApprovals {
+		return fmt.Sprintf("paper requires %d valid approvals, has %d", paper.RequiredApprovals, count), nil
+	}
+
+	if len(approvedOrgs) < paper.RequiredApproverOrgs {
+		approved := make([]string, 0, len(approvedOrgs))
+		for org := range approvedOrgs {
+			approved = append(approved, org)
+		}
+		sort.Strings(approved)
+
+		pending := []string{}
+		for _, org := range paper.ApproverOrgs {
+			if !approvedOrgs[org] {
+				pending = append(pending, org)
+			}
+		}
+		sort.Strings(pending)
+
+		return fmt.Sprintf("paper requires approvals from %d distinct orgs, approved by %d %v, not yet approved by %v",
+			paper.RequiredApproverOrgs, len(approved), approved, pending), nil
+	}
+
+	return "", nil
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// unlockRetryWait 距上次失败的解锁尝试不足退避间隔时返回 retry later 原因
+func unlockRetryWait(ctx contractapi.TransactionContextInterface, paper *ExamPaper) (string, error) {
+	if paper.AttemptCount == 0 || paper.LastUnlockAttempt == "" {
+		return "", nil
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	if config.UnlockRetryBackoff == 0 {
+		return "", nil
+	}
+
+	lastAttempt, err := parseTime(paper.LastUnlockAttempt)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse last unlock attempt: %v", err)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	retryAt := lastAttempt.Add(time.Duration(config.UnlockRetryBackoff) * time.Second)
+	if now.Before(retryAt) {
+		return fmt.Sprintf("retry later: %d failed unlock attempt(s), next attempt allowed at %s",
+			paper.AttemptCount, retryAt.Format(time.RFC3339)), nil
+	}
+	return "", nil
+}
+
+// markUnlocked 将试卷置为 unlocked 并清零失败尝试记录后写入，调用方须已检查解锁前置条件
+func markUnlocked(ctx contractapi.TransactionContextInterface, paper *ExamPaper) error {
+	if err := checkStatusChange(paper, "unlocked", true); err != nil {
+		return err
+	}
+
+	paper.Status = "unlocked"
+	paper.LastUnlockAttempt = ""
+	paper.AttemptCount = 0
+
+	return savePaper(ctx, paper)
+}
+
+// retentionExpired 判断归档试卷的保留期限是否已过（未记录期限的旧数据视为已过）
+func retentionExpired(paper *ExamPaper, now time.Time) (bool, error) {
+	if paper.RetentionUntil == "" {
+		return true, nil
+	}
+	retentionUntil, err := parseTime(paper.RetentionUntil)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse retention time: %v", err)
+	}
+	return !now.Before(retentionUntil), nil
+}
+
+// getUnlockApprovals 读取试卷的全部解锁审批
+func getUnlockApprovals(ctx contractapi.TransactionContextInterface, paperID string) ([]*UnlockApproval, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("UnlockApproval", []string{paperID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get approvals: %v", err)
+	}
+	defer iterator.Close()
+
+	var approvals []*UnlockApproval
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var approval UnlockApproval
+		err = json.Unmarshal(result.Value, &approval)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, &approval)
+	}
+
+	return approvals, nil
+}
+
+// isApprovalValid 判断审批是否仍在有效期内（相对交易时间）
+func isApprovalValid(ctx contractapi.TransactionContextInterface, approval *UnlockApproval) (bool, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return false, err
+	}
+	if config.ApprovalValiditySeconds == 0 {
+		return true, nil
+	}
+
+	approvedAt, err := parseTime(approval.ApprovedAt)
+	if err != nil {
+		return false, nil
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	expiresAt := approvedAt.Add(time.Duration(config.ApprovalValiditySeconds) * time.Second)
+	return now.Before(expiresAt), nil
+}
+
+// uploaderRoles 可管理试卷内容（预留试卷ID、签发上传令牌、修改试卷和答案等）的角色
+var uploaderRoles = []string{"admin", "coe", "teacher"}
+
+// requireUploader 要求调用者具有 uploaderRoles 之一，或其身份ID在配置的 GatewayIdentities 中
+func requireUploader(ctx contractapi.TransactionContextInterface) error {
+	roleErr := requireRole(ctx, uploaderRoles...)
+	if roleErr == nil {
+		return nil
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if len(config.GatewayIdentities) > 0 {
+		clientID, err := ctx.GetClientIdentity().GetID()
+		if err != nil {
+			return fmt.Errorf("failed to get client identity: %v", err)
+		}
+		if containsString(config.GatewayIdentities, clientID) {
+			return nil
+		}
+	}
+	return roleErr
+}
+
+// getUploadToken 读取委托上传令牌
+func getUploadToken(ctx contractapi.TransactionContextInterface, tokenID string) (*UploadToken, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("UploadToken", []string{tokenID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	tokenJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if tokenJSON == nil {
+		return nil, fmt.Errorf("upload token %s does not exist", tokenID)
+	}
+
+	var token UploadToken
+	err = json.Unmarshal(tokenJSON, &token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal upload token: %v", err)
+	}
+	return &token, nil
+}
+
+// putUploadToken 写入委托上传令牌
+func putUploadToken(ctx contractapi.TransactionContextInterface, token *UploadToken) error {
+	key, err := ctx.GetStub().CreateCompositeKey("UploadToken", []string{token.TokenID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload token: %v", err)
+	}
+
+	return ctx.GetStub().PutState(key, tokenJSON)
+}
+
+// redeemUploadToken 校验并消费委托上传令牌
+func redeemUploadToken(ctx contractapi.TransactionContextInterface, tokenID string, examID string) (*UploadToken, error) {
+	token, err := getUploadToken(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if token.ExamID != examID {
+		return nil, fmt.Errorf("upload token %s is not valid for exam %s", tokenID, examID)
+	}
+	if token.Consumed {
+		return nil, fmt.Errorf("upload token %s has already been used", tokenID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	validUntil, err := parseTime(token.ValidUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token validity: %v", err)
+	}
+	if !now.Before(validUntil) {
+		return nil, fmt.Errorf("upload token %s expired at %s", tokenID, token.ValidUntil)
+	}
+
+	redeemer, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client identity: %v", err)
+	}
+
+	token.Consumed = true
+	token.ConsumedBy = redeemer
+	token.ConsumedAt = now.Format(time.RFC3339)
+
+	err = putUploadToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// orphanLogActions 试卷可能已被删除时仍允许记录的动作
+var orphanLogActions = map[string]bool{"delete": true, "emergency": true}
+
+// recordAccess 写入访问日志（内部使用，调用方负责确认试卷存在）
+func recordAccess(
+	ctx contractapi.TransactionContextInterface,
+	paperID string,
+	userID string,
+	action string,
+	ipAddress string,
+	details string,
+) error {
+	return putAccessLog(ctx, &AccessLog{
+		PaperID:   paperID,
+		UserID:    userID,
+		Action:    action,
+		IPAddress: ipAddress,
+		Details:   details,
+	})
+}
+
+// putAccessLog 以交易ID和交易时间填充日志ID、时间戳后写入访问日志
+func putAccessLog(ctx contractapi.TransactionContextInterface, log *AccessLog) error {
+	txID := ctx.GetStub().GetTxID()
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.LogID = fmt.Sprintf("LOG_%s", txID[:16])
+	log.Timestamp = txTime.Format(time.RFC3339)
+
+	// 追加到试卷日志哈希链（同一交易中每份试卷至多写一条日志，LogID 由交易ID决定）
+	head, err := getLogChainHead(ctx, log.PaperID)
+	if err != nil {
+		return err
+	}
+	log.Seq = head.Seq + 1
+	log.PrevHash = head.Hash
+	log.Hash, err = computeLogHash(log)
+	if err != nil {
+		return err
+	}
+	if err := putLogChainHead(ctx, log.PaperID, &logChainHead{Seq: log.Seq, Hash: log.Hash}); err != nil {
+		return err
+	}
+
+	logJSON, err := json.Marshal(log)
+	if err != nil {
+		return fmt.Errorf("failed to marshal log: %v", err)
+	}
+
+	// 使用复合键存储日志
+	compositeKey, err := ctx.GetStub().CreateCompositeKey("AccessLog", []string{log.PaperID, log.LogID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	return ctx.GetStub().PutState(compositeKey, logJSON)
+}
+
+// logChainHead 试卷日志哈希链的链头（最后一条日志的序号和哈希）
+type logChainHead struct {
+	Seq  int    `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// logChainHeadKeyType 日志链头的复合键类型，键为 (paperID)
+const logChainHeadKeyType = "LogChainHead"
+
+// getLogChainHead 读取试卷的日志链头，尚无链上日志时返回零值
+func getLogChainHead(ctx contractapi.TransactionContextInterface, paperID string) (*logChainHead, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(logChainHeadKeyType, []string{paperID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	headJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	head := &logChainHead{}
+	if headJSON == nil {
+		return head, nil
+	}
+	if err := json.Unmarshal(headJSON, head); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal log chain head: %v", err)
+	}
+	return head, nil
+}
+
+// putLogChainHead 写入试卷的日志链头
+func putLogChainHead(ctx contractapi.TransactionContextInterface, paperID string, head *logChainHead) error {
+	key, err := ctx.GetStub().CreateCompositeKey(logChainHeadKeyType, []string{paperID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	headJSON, err := json.Marshal(head)
+	if err != nil {
+		return fmt.Errorf("failed to marshal log chain head: %v", err)
+	}
+
+	return ctx.GetStub().PutState(key, headJSON)
+}
+
+// logHashContent 参与日志哈希计算的字段（字段顺序即规范序列化顺序）
+// 不含 paper_id，试卷改名迁移日志后哈希链仍然有效
+type logHashContent struct {
+	LogID     string `json:"log_id"`
+	UserID    string `json:"user_id"`
+	Action    string `json:"action"`
+	Timestamp string `json:"timestamp"`
+	IPAddress string `json:"ip_address"`
+	Details   string `json:"details"`
+	Location  string `json:"location"`
+	Seq       int    `json:"seq"`
+	PrevHash  string `json:"prev_hash"`
+
+	KeyFingerprint string `json:"key_fingerprint,omitempty"` // 省略为空值，保证既有日志的哈希不变
+}
+
+// computeLogHash 计算日志的 SHA-256 哈希
+func computeLogHash(log *AccessLog) (string, error) {
+	content, err := json.Marshal(logHashContent{
+		LogID:     log.LogID,
+		UserID:    log.UserID,
+		Action:    log.Action,
+		Timestamp: log.Timestamp,
+		IPAddress: log.IPAddress,
+		Details:   log.Details,
+		Location:  log.Location,
+		Seq:       log.Seq,
+		PrevHash:  log.PrevHash,
+
+		KeyFingerprint: log.KeyFingerprint,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal log: %v", err)
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// verifyLogChain 按序号校验日志哈希链，BreakIndex 为第一处断裂的序号
+func verifyLogChain(ctx contractapi.TransactionContextInterface, paperID string, logs []*AccessLog) (*LogChainResult, error) {
+	result := &LogChainResult{PaperID: paperID, Intact: true, BreakIndex: -1}
+
+	chained := []*AccessLog{}
+	for _, log := range logs {
+		if log.Seq == 0 {
+			result.Unchained++
+			continue
+		}
+		chained = append(chained, log)
+	}
+	sort.Slice(chained, func(i, j int) bool { return chained[i].Seq < chained[j].Seq })
+	result.Length = len(chained)
+
+	broken := func(index int, reason string) (*LogChainResult, error) {
+		result.Intact = false
+		result.BreakIndex = index
+		result.Reason = reason
+		return result, nil
+	}
+
+	prevHash := ""
+	for i, log := range chained {
+		expectedSeq := i + 1
+		if log.Seq != expectedSeq {
+			return broken(expectedSeq, fmt.Sprintf("log with seq %d is missing", expectedSeq))
+		}
+		if log.PrevHash != prevHash {
+			return broken(log.Seq, fmt.Sprintf("log %s does not link to the previous log", log.LogID))
+		}
+		hash, err := computeLogHash(log)
+		if err != nil {
+			return nil, err
+		}
+		if hash != log.Hash {
+			return broken(log.Seq, fmt.Sprintf("log %s has been modified", log.LogID))
+		}
+		prevHash = log.Hash
+	}
+
+	// 链尾被删除时只能通过链头发现
+	head, err := getLogChainHead(ctx, paperID)
+	if err != nil {
+		return nil, err
+	}
+	if head.Seq != len(chained) || head.Hash != prevHash {
+		return broken(len(chained)+1, fmt.Sprintf("chain head (seq %d) does not match the last of %d log(s)", head.Seq, len(chained)))
+	}
+
+	return result, nil
+}
+
+// paperVersion 试卷在历史中的一个版本
+type paperVersion struct {
+	TxID      string
+	Timestamp time.Time
+	IsDelete  bool
+	Paper     ExamPaper
+}
+
+// getPaperVersions 读取试卷的历史版本，按时间从旧到新排列（GetHistoryForKey 返回从新到旧）
+func getPaperVersions(ctx contractapi.TransactionContextInterface, paperID string) ([]*paperVersion, error) {
+	iterator, err := ctx.GetStub().GetHistoryForKey(paperID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history: %v", err)
+	}
+	defer iterator.Close()
+
+	var versions []*paperVersion
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		v := &paperVersion{
+			TxID:      result.TxId,
+			Timestamp: time.Unix(result.Timestamp.Seconds, int64(result.Timestamp.Nanos)).UTC(),
+			IsDelete:  result.IsDelete,
+		}
+		if len(result.Value) > 0 {
+			if err := json.Unmarshal(result.Value, &v.Paper); err != nil {
+				continue
+			}
+		}
+		versions = append(versions, v)
+	}
+
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
+	}
+	return versions, nil
+}
+
+// getPaper 读取完整的试卷记录（内部使用，不做脱敏）
+func getPaper(ctx contractapi.TransactionContextInterface, paperID string) (*ExamPaper, error) {
+	paperJSON, err := ctx.GetStub().GetState(paperID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if paperJSON == nil {
+		return nil, fmt.Errorf("paper %s does not exist", paperID)
+	}
+
+	var paper ExamPaper
+	err = json.Unmarshal(paperJSON, &paper)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal paper: %v", err)
+	}
+
+	return &paper, nil
+}
+
+// callerUserID 获取调用者的用户ID（证书中的 hf.EnrollmentID 属性）
+func callerUserID(ctx contractapi.TransactionContextInterface) (string, error) {
+	userID, found, err := ctx.GetClientIdentity().GetAttributeValue("hf.EnrollmentID")
+	if err != nil {
+		return "", fmt.Errorf("failed to read client identity: %v", err)
+	}
+	if !found {
+		return "", nil
+	}
+	return userID, nil
+}
+
+// redactForCaller 已公开的试卷不做隐去；查看名单非空且调用者既不在名单中也不是管理员时，隐去试卷的敏感字段；
+// 预览未发布时隐去预览哈希；答案尚未到发布时间时，对非管理员隐去答案承诺。需要隐去时返回副本
+func redactForCaller(ctx contractapi.TransactionContextInterface, paper *ExamPaper) (*ExamPaper, error) {
+	if paper.Published || requireRole(ctx, "admin") == nil {
+		return paper, nil
+	}
+
+	visible := paper
+	if len(paper.AllowedViewers) > 0 {
+		userID, err := callerUserID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		allowed := false
+		for _, v := range paper.AllowedViewers {
+			if userID != "" && v == userID {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			redacted := *paper
+			redacted.IPFSHash = ""
+			redacted.FileHash = ""
+			redacted.Replicas = nil
+			redacted.AnswerKeyCommitment = ""
+			redacted.AllowedViewers = nil
+			visible = &redacted
+		}
+	}
+
+	if visible.PreviewIPFSHash != "" && !visible.PreviewReleased {
+		unreleased := *visible
+		unreleased.PreviewIPFSHash = ""
+		visible = &unreleased
+	}
+
+	if visible.AnswerKeyCommitment != "" {
+		now, err := getTxTime(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// 发布时间无法解析时按未发布处理，不影响试卷其余字段的读取
+		released, _, err := answerKeyReleased(paper, now)
+		if err != nil || !released {
+			sealed := *visible
+			sealed.AnswerKeyCommitment = ""
+			visible = &sealed
+		}
+	}
+
+	return visible, nil
+}
+
+// redactPapersForCaller 对列表中的每份试卷执行 redactForCaller，返回试卷列表的查询方法都须经过这里
+func redactPapersForCaller(ctx contractapi.TransactionContextInterface, papers []*ExamPaper) ([]*ExamPaper, error) {
+	visible := make([]*ExamPaper, 0, len(papers))
+	for _, paper := range papers {
+		v, err := redactForCaller(ctx, paper)
+		if err != nil {
+			return nil, err
+		}
+		visible = append(visible, v)
+	}
+	return visible, nil
+}
+
+// queryVisiblePapers 执行 queryPapers 并按调用者权限隐去敏感字段，供直接返回查询结果的方法使用
+func queryVisiblePapers(ctx contractapi.TransactionContextInterface, selector map[string]interface{}) ([]*ExamPaper, error) {
+	papers, err := queryPapers(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	return redactPapersForCaller(ctx, papers)
+}
+
+// answerKeyReleased 判断答案是否已到发布时间，同时返回生效的发布时间
+// 未单独设置 AnswerKeyUnlockTime 时沿用试卷的解锁时间
+func answerKeyReleased(paper *ExamPaper, now time.Time) (bool, string, error) {
+	releaseAt := paper.AnswerKeyUnlockTime
+	if releaseAt == "" {
+		releaseAt = paper.UnlockTime
+	}
+
+	releaseTime, err := parseTime(releaseAt)
+	if err != nil {
+		return false, releaseAt, fmt.Errorf("failed to parse answer key unlock time: %v", err)
+	}
+	return !now.Before(releaseTime), releaseAt, nil
+}
+
+// checkExamPaperLimit 考试的试卷数已达到 MaxPapersPerExam 时拒绝新增试卷（防止异常客户端循环上传）
+func checkExamPaperLimit(ctx contractapi.TransactionContextInterface, examID string) error {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if config.MaxPapersPerExam <= 0 {
+		return nil
+	}
+
+	papers, err := queryPapers(ctx, map[string]interface{}{
+		"exam_id":  examID,
+		"paper_id": map[string]interface{}{"$exists": true},
+	})
+	if err != nil {
+		return err
+	}
+	if len(papers) >= config.MaxPapersPerExam {
+		return fmt.Errorf("exam %s already has %d papers, the limit is %d per exam", examID, len(papers), config.MaxPapersPerExam)
+	}
+	return nil
+}
+
+// checkNotFrozen 试卷处于调查冻结时返回错误
+func checkNotFrozen(paper *ExamPaper) error {
+	if paper.Frozen {
+		return fmt.Errorf("paper %s is frozen for investigation (case %s)", paper.PaperID, paper.FrozenCaseRef)
+	}
+	return nil
+}
+
+// checkPaperWrite 校验对账本中已有试卷的覆盖写入：冻结中的试卷只允许解冻；已公开的试卷不能撤销公开或变更状态；
+// 不可变字段不得修改，已有值为空时允许首次填充（如预留草稿的上传者）
+func checkPaperWrite(ctx contractapi.TransactionContextInterface, paper *ExamPaper) error {
+	existingJSON, err := ctx.GetStub().GetState(paper.PaperID)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existingJSON == nil {
+		return nil
+	}
+
+	var existing ExamPaper
+	if err := json.Unmarshal(existingJSON, &existing); err != nil {
+		return fmt.Errorf("failed to unmarshal paper: %v", err)
+	}
+	// 冻结标志只能由 Freeze/Unfreeze 修改，其余写入都会保持 Frozen 为 true
+	if existing.Frozen && paper.Frozen {
+		return checkNotFrozen(&existing)
+	}
+	// 公开是终态标记：不能撤销，也不能再变更状态
+	if existing.Published && (!paper.Published || paper.Status != existing.Status) {
+		return fmt.Errorf("paper %s is published, published papers are terminal and cannot change status", paper.PaperID)
+	}
+
+	before, err := paperFields(&existing)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get history: %v", err)
+		return err
+	}
+	after, err := paperFields(paper)
+	if err != nil {
+		return err
+	}
+
+	fields := make([]string, 0, len(immutablePaperFields))
+	for field := range immutablePaperFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		old, ok := before[field]
+		if !ok || string(old) == `""` {
+			continue
+		}
+		if !bytes.Equal(old, after[field]) {
+			return fmt.Errorf("field %s of paper %s is immutable", field, paper.PaperID)
+		}
+	}
+	return nil
+}
+
+// savePaper 以交易时间更新 UpdatedAt、递增 Revision 后序列化并写入试卷
+func savePaper(ctx contractapi.TransactionContextInterface, paper *ExamPaper) error {
+	if err := checkPaperWrite(ctx, paper); err != nil {
+		return err
+	}
+	if err := touchPaper(ctx, paper); err != nil {
+		return err
+	}
+	paper.Revision++
+
+	paperJSON, err := json.Marshal(paper)
+	if err != nil {
+		return fmt.Errorf("failed to marshal paper: %v", err)
+	}
+
+	return ctx.GetStub().PutState(paper.PaperID, paperJSON)
+}
+
+// queryPapers 按 CouchDB selector 查询试卷
+// 状态数据库为 LevelDB（不支持富查询）时退化为全量范围扫描，并在链码内按 selector 过滤
+func queryPapers(ctx contractapi.TransactionContextInterface, selector map[string]interface{}) ([]*ExamPaper, error) {
+	query, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	iterator, err := ctx.GetStub().GetQueryResult(string(query))
+	if err != nil {
+		if strings.Contains(err.Error(), "not supported") {
+			return scanPapers(ctx, selector)
+		}
+		return nil, fmt.Errorf("failed to query: %v", err)
 	}
 	defer iterator.Close()
 
-	var history []map[string]interface{}
+	var papers []*ExamPaper
 	for iterator.HasNext() {
 		result, err := iterator.Next()
 		if err != nil {
@@ -319,38 +7754,21 @@ func (c *ExamPaperContract) GetPaperHistory(
 		}
 
 		var paper ExamPaper
-		if len(result.Value) > 0 {
-			err = json.Unmarshal(result.Value, &paper)
-			if err != nil {
-				continue
-			}
-		}
-
-		record := map[string]interface{}{
-			"tx_id":     result.TxId,
-			"timestamp": time.Unix(result.Timestamp.Seconds, int64(result.Timestamp.Nanos)).Format(time.RFC3339),
-			"is_delete": result.IsDelete,
-			"paper":     paper,
+		err = json.Unmarshal(result.Value, &paper)
+		if err != nil {
+			continue
 		}
-		history = append(history, record)
+		papers = append(papers, &paper)
 	}
 
-	return history, nil
+	return papers, nil
 }
 
-// ===================== 批量查询 =====================
-
-// GetAllPapers 获取所有试卷（分页）
-func (c *ExamPaperContract) GetAllPapers(
-	ctx contractapi.TransactionContextInterface,
-	pageSize int32,
-	bookmark string,
-) (*PaginatedResult, error) {
-	query := `{"selector":{"paper_id":{"$gt":""}}}`
-
-	iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, pageSize, bookmark)
+// scanPapers 范围扫描全部试卷记录，按 selector 过滤（仅支持 queryPapers 用到的等值、$in、$exists 和字符串范围比较）
+func scanPapers(ctx contractapi.TransactionContextInterface, selector map[string]interface{}) ([]*ExamPaper, error) {
+	iterator, err := ctx.GetStub().GetStateByRange("", "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to query: %v", err)
+		return nil, fmt.Errorf("failed to get state by range: %v", err)
 	}
 	defer iterator.Close()
 
@@ -362,72 +7780,473 @@ func (c *ExamPaperContract) GetAllPapers(
 		}
 
 		var paper ExamPaper
-		err = json.Unmarshal(result.Value, &paper)
-		if err != nil {
+		if err := json.Unmarshal(result.Value, &paper); err != nil {
 			continue
 		}
-		papers = append(papers, &paper)
+		if paper.PaperID == "" || paper.PaperID != result.Key {
+			continue
+		}
+
+		matched, err := matchSelector(&paper, selector)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			papers = append(papers, &paper)
+		}
 	}
 
-	return &PaginatedResult{
-		Papers:       papers,
-		RecordCount:  metadata.FetchedRecordsCount,
-		Bookmark:     metadata.Bookmark,
-	}, nil
+	return papers, nil
 }
 
-// PaginatedResult 分页结果
-type PaginatedResult struct {
-	Papers      []*ExamPaper `json:"papers"`
-	RecordCount int32        `json:"record_count"`
-	Bookmark    string       `json:"bookmark"`
+// matchSelector 在链码内对试卷求值 CouchDB selector 的子集
+func matchSelector(paper *ExamPaper, selector map[string]interface{}) (bool, error) {
+	fields, err := paperFields(paper)
+	if err != nil {
+		return false, err
+	}
+
+	for name, condition := range selector {
+		raw, exists := fields[name]
+		var value string
+		if exists {
+			// 非字符串字段解析失败时保持空值，只会影响等值和 $in 比较
+			_ = json.Unmarshal(raw, &value)
+		}
+
+		switch cond := condition.(type) {
+		case string:
+			if !exists || value != cond {
+				return false, nil
+			}
+		case map[string]interface{}:
+			for op, arg := range cond {
+				switch op {
+				case "$exists":
+					want, _ := arg.(bool)
+					if exists != want {
+						return false, nil
+					}
+				case "$in":
+					options, ok := arg.([]string)
+					if !ok {
+						return false, fmt.Errorf("unsupported $in argument for field %s", name)
+					}
+					found := false
+					for _, option := range options {
+						if exists && value == option {
+							found = true
+							break
+						}
+					}
+					if !found {
+						return false, nil
+					}
+				case "$gt", "$gte", "$lt", "$lte":
+					bound, ok := arg.(string)
+					if !ok {
+						return false, fmt.Errorf("unsupported %s argument for field %s", op, name)
+					}
+					if !exists || !compareStrings(value, op, bound) {
+						return false, nil
+					}
+				default:
+					return false, fmt.Errorf("selector operator %s is not supported without CouchDB", op)
+				}
+			}
+		default:
+			return false, fmt.Errorf("unsupported selector condition for field %s", name)
+		}
+	}
+
+	return true, nil
 }
 
-// GetPapersByExam 按考试ID查询试卷
-func (c *ExamPaperContract) GetPapersByExam(
-	ctx contractapi.TransactionContextInterface,
-	examID string,
-) ([]*ExamPaper, error) {
-	query := fmt.Sprintf(`{"selector":{"exam_id":"%s"}}`, examID)
+// compareStrings 按 CouchDB 比较运算符比较字符串
+func compareStrings(value string, op string, bound string) bool {
+	switch op {
+	case "$gt":
+		return value > bound
+	case "$gte":
+		return value >= bound
+	case "$lt":
+		return value < bound
+	case "$lte":
+		return value <= bound
+	}
+	return false
+}
+
+// 试卷二级索引名
+const (
+	uploaderIndex = "Uploader~Paper" // uploadedBy -> paperID
+	fileHashIndex = "FileHash~Paper" // fileHash -> paperID
+)
+
+// paperIndex 试卷二级索引定义：索引名及取索引值的字段
+type paperIndex struct {
+	name  string
+	value func(paper *ExamPaper) string
+}
+
+// paperIndexes 试卷的全部二级索引，键为 name(value, paperID)，值为空字节
+var paperIndexes = []paperIndex{
+	{name: uploaderIndex, value: func(p *ExamPaper) string { return p.UploadedBy }},
+	{name: fileHashIndex, value: func(p *ExamPaper) string { return p.FileHash }},
+}
 
-	iterator, err := ctx.GetStub().GetQueryResult(query)
+// getPaperIDsByIndex 通过二级索引查找试卷ID
+func getPaperIDsByIndex(ctx contractapi.TransactionContextInterface, indexName string, value string) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(indexName, []string{value})
 	if err != nil {
-		return nil, fmt.Errorf("failed to query: %v", err)
+		return nil, fmt.Errorf("failed to get index %s: %v", indexName, err)
 	}
 	defer iterator.Close()
 
-	var papers []*ExamPaper
+	var paperIDs []string
 	for iterator.HasNext() {
 		result, err := iterator.Next()
 		if err != nil {
 			return nil, err
 		}
 
-		var paper ExamPaper
-		err = json.Unmarshal(result.Value, &paper)
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(result.Key)
 		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+		paperIDs = append(paperIDs, attrs[1])
+	}
+	return paperIDs, nil
+}
+
+// putPaperIndexes 写入试卷的二级索引，字段为空时跳过
+func putPaperIndexes(ctx contractapi.TransactionContextInterface, paper *ExamPaper) error {
+	for _, index := range paperIndexes {
+		value := index.value(paper)
+		if value == "" {
 			continue
 		}
-		papers = append(papers, &paper)
+
+		key, err := ctx.GetStub().CreateCompositeKey(index.name, []string{value, paper.PaperID})
+		if err != nil {
+			return fmt.Errorf("failed to create composite key: %v", err)
+		}
+		if err := ctx.GetStub().PutState(key, []byte{0x00}); err != nil {
+			return fmt.Errorf("failed to put index %s: %v", index.name, err)
+		}
 	}
+	return nil
+}
 
-	return papers, nil
+// deletePaperIndexes 删除试卷的二级索引
+func deletePaperIndexes(ctx contractapi.TransactionContextInterface, paper *ExamPaper) error {
+	for _, index := range paperIndexes {
+		value := index.value(paper)
+		if value == "" {
+			continue
+		}
+
+		key, err := ctx.GetStub().CreateCompositeKey(index.name, []string{value, paper.PaperID})
+		if err != nil {
+			return fmt.Errorf("failed to create composite key: %v", err)
+		}
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return fmt.Errorf("failed to delete index %s: %v", index.name, err)
+		}
+	}
+	return nil
 }
 
-// ===================== 验证 =====================
+// paperScopedKeyTypes 以 paperID 为第一个属性的复合键类型，试卷改名时需要一并迁移，清理考试时一并删除
+var paperScopedKeyTypes = []string{"AccessLog", "UnlockApproval", answerKeyVersionKeyType, paperLinkKeyType, logChainHeadKeyType, incidentKeyType, submissionKeyType, paperNoteKeyType, accessDedupKeyType}
 
-// VerifyPaperHash 验证试卷哈希
-func (c *ExamPaperContract) VerifyPaperHash(
-	ctx contractapi.TransactionContextInterface,
-	paperID string,
-	providedHash string,
-) (bool, error) {
-	paper, err := c.GetPaper(ctx, paperID)
+// movePaperScopedKeys 将 oldID 下的所有复合键迁移到 newID，并更新值中的 paper_id
+func movePaperScopedKeys(ctx contractapi.TransactionContextInterface, oldID string, newID string) error {
+	stub := ctx.GetStub()
+	for _, objectType := range paperScopedKeyTypes {
+		iterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{oldID})
+		if err != nil {
+			return fmt.Errorf("failed to get %s entries: %v", objectType, err)
+		}
+
+		for iterator.HasNext() {
+			result, err := iterator.Next()
+			if err != nil {
+				iterator.Close()
+				return err
+			}
+
+			_, attrs, err := stub.SplitCompositeKey(result.Key)
+			if err != nil {
+				iterator.Close()
+				return fmt.Errorf("failed to split composite key: %v", err)
+			}
+			attrs[0] = newID
+
+			newKey, err := stub.CreateCompositeKey(objectType, attrs)
+			if err != nil {
+				iterator.Close()
+				return fmt.Errorf("failed to create composite key: %v", err)
+			}
+
+			value, err := replacePaperIDField(result.Value, newID)
+			if err != nil {
+				iterator.Close()
+				return err
+			}
+
+			if err := stub.PutState(newKey, value); err != nil {
+				iterator.Close()
+				return fmt.Errorf("failed to put state: %v", err)
+			}
+			if err := stub.DelState(result.Key); err != nil {
+				iterator.Close()
+				return fmt.Errorf("failed to delete state: %v", err)
+			}
+		}
+		iterator.Close()
+	}
+	return nil
+}
+
+// deletePaperScopedKeys 删除试卷下的所有复合键（访问日志、审批等）
+func deletePaperScopedKeys(ctx contractapi.TransactionContextInterface, paperID string) error {
+	stub := ctx.GetStub()
+	for _, objectType := range paperScopedKeyTypes {
+		iterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{paperID})
+		if err != nil {
+			return fmt.Errorf("failed to get %s entries: %v", objectType, err)
+		}
+
+		for iterator.HasNext() {
+			result, err := iterator.Next()
+			if err != nil {
+				iterator.Close()
+				return err
+			}
+			if err := stub.DelState(result.Key); err != nil {
+				iterator.Close()
+				return fmt.Errorf("failed to delete state: %v", err)
+			}
+		}
+		iterator.Close()
+	}
+	return nil
+}
+
+// replacePaperIDField 替换 JSON 值中的 paper_id 字段（无该字段时原样返回）
+func replacePaperIDField(value []byte, newID string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return value, nil
+	}
+	if _, ok := fields["paper_id"]; !ok {
+		return value, nil
+	}
+
+	fields["paper_id"] = newID
+	updated, err := json.Marshal(fields)
 	if err != nil {
-		return false, err
+		return nil, fmt.Errorf("failed to marshal entry: %v", err)
+	}
+	return updated, nil
+}
+
+// emitEvent 序列化并发出链码事件（每个交易只保留最后一次设置的事件）
+// 事件在全局或所属考试的事件配置中被屏蔽时跳过
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, examID string, payload interface{}) error {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if config.Events.suppressed(name, examID) {
+		return nil
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent(name, payloadJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+	return nil
+}
+
+// paperMeta 参与元数据指纹计算的不可变字段（字段顺序即规范序列化顺序）
+type paperMeta struct {
+	PaperID    string `json:"paper_id"`
+	ExamID     string `json:"exam_id"`
+	IPFSHash   string `json:"ipfs_hash"`
+	FileHash   string `json:"file_hash"`
+	CreatedAt  string `json:"created_at"`
+	UploadedBy string `json:"uploaded_by"`
+}
+
+// signedPaperContent 分离签名覆盖的试卷内容（字段顺序即规范序列化顺序）
+// 仅包含上传前即可确定的字段，不含创建时间等由账本生成的值
+type signedPaperContent struct {
+	PaperID    string `json:"paper_id"`
+	ExamID     string `json:"exam_id"`
+	Subject    string `json:"subject"`
+	IPFSHash   string `json:"ipfs_hash"`
+	FileHash   string `json:"file_hash"`
+	HashAlgo   string `json:"hash_algo"`
+	UnlockTime string `json:"unlock_time"`
+	UploadedBy string `json:"uploaded_by"`
+}
+
+// canonicalPaperContent 生成分离签名的规范 JSON；ECDSA/RSA 对其 SHA-256 摘要签名，Ed25519 直接对其签名
+func canonicalPaperContent(paper *ExamPaper) ([]byte, error) {
+	content, err := json.Marshal(signedPaperContent{
+		PaperID:    paper.PaperID,
+		ExamID:     paper.ExamID,
+		Subject:    paper.Subject,
+		IPFSHash:   paper.IPFSHash,
+		FileHash:   paper.FileHash,
+		HashAlgo:   paper.HashAlgo,
+		UnlockTime: paper.UnlockTime,
+		UploadedBy: paper.UploadedBy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal paper content: %v", err)
+	}
+	return content, nil
+}
+
+// computeMetaHash 计算试卷不可变元数据的 SHA-256 指纹（不含状态、更新时间等可变字段）
+func computeMetaHash(paper *ExamPaper) (string, error) {
+	metaJSON, err := json.Marshal(paperMeta{
+		PaperID:    paper.PaperID,
+		ExamID:     paper.ExamID,
+		IPFSHash:   paper.IPFSHash,
+		FileHash:   paper.FileHash,
+		CreatedAt:  paper.CreatedAt,
+		UploadedBy: paper.UploadedBy,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal paper metadata: %v", err)
+	}
+
+	sum := sha256.Sum256(metaJSON)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// touchPaper 将 UpdatedAt 设为交易时间，并保证其不早于原值
+// 时钟偏差导致回退时，按配置钳制为原值或拒绝交易
+func touchPaper(ctx contractapi.TransactionContextInterface, paper *ExamPaper) error {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if paper.UpdatedAt != "" {
+		previous, err := parseTime(paper.UpdatedAt)
+		if err == nil && now.Before(previous) {
+			config, err := getConfig(ctx)
+			if err != nil {
+				return err
+			}
+			if config.RejectClockSkew {
+				return fmt.Errorf("tx timestamp %s is earlier than last update %s", now.Format(time.RFC3339), paper.UpdatedAt)
+			}
+			return nil
+		}
+	}
+
+	paper.UpdatedAt = now.Format(time.RFC3339)
+	return nil
+}
+
+// maxBookmarkLength 分页书签的最大长度
+const maxBookmarkLength = 4096
+
+// validateBookmark 检查 CouchDB 分页书签格式（base64url，无填充），空书签合法
+// 明显无效的书签直接返回可读的错误，而不是交给 Fabric 返回底层错误
+func validateBookmark(bookmark string) error {
+	if bookmark == "" {
+		return nil
+	}
+	if len(bookmark) > maxBookmarkLength {
+		return fmt.Errorf("invalid bookmark: longer than %d characters", maxBookmarkLength)
+	}
+	if _, err := base64.RawURLEncoding.DecodeString(bookmark); err != nil {
+		return fmt.Errorf("invalid bookmark %q: use the bookmark returned by the previous page, or empty to start", bookmark)
+	}
+	return nil
+}
+
+// validateIPFSHash 验证IPFS哈希格式 (Qm开头，46字符)，只接受裸 CID，不接受网关 URL 或路径
+func validateIPFSHash(cid string) error {
+	if strings.Contains(cid, "://") || strings.Contains(cid, "/") {
+		return fmt.Errorf("IPFS hash %s looks like a URL or path, store the bare CID only (e.g. Qm...)", cid)
+	}
+	if len(cid) < 46 || cid[:2] != "Qm" {
+		return fmt.Errorf("invalid IPFS hash format: %s", cid)
+	}
+	return nil
+}
+
+// fileHashHexLength 支持的文件哈希算法及其十六进制摘要长度
+var fileHashHexLength = map[string]int{
+	"SM3":    64,
+	"SHA256": 64,
+	"SHA512": 128,
+}
+
+// normalizeHashAlgo 规范化哈希算法名称（如 sha-256 -> SHA256），为空时默认 SM3
+func normalizeHashAlgo(algo string) string {
+	algo = strings.ToUpper(strings.ReplaceAll(algo, "-", ""))
+	if algo == "" {
+		return "SM3"
+	}
+	return algo
+}
+
+// normalizeEncryptionScheme 规范化加密方案名称（如 sm4-cbc -> SM4-CBC），为空时默认 SM4-CBC
+func normalizeEncryptionScheme(scheme string) string {
+	scheme = strings.ToUpper(strings.TrimSpace(scheme))
+	if scheme == "" {
+		return "SM4-CBC"
+	}
+	return scheme
+}
+
+// validateFileHash 校验文件哈希符合所声明算法的编码与长度（十六进制摘要）
+func validateFileHash(algo string, hash string) error {
+	length, ok := fileHashHexLength[algo]
+	if !ok {
+		return fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+	if len(hash) != length {
+		return fmt.Errorf("invalid %s file hash: expected %d hex characters, got %d", algo, length, len(hash))
 	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return fmt.Errorf("invalid %s file hash: must be hex encoded", algo)
+	}
+	return nil
+}
 
-	return paper.FileHash == providedHash, nil
+// normalizeReplicas 校验副本CID并去重，忽略与主哈希相同的条目
+func normalizeReplicas(primary string, replicas []string) ([]string, error) {
+	var result []string
+	seen := map[string]bool{primary: true}
+	for _, r := range replicas {
+		if err := validateIPFSHash(r); err != nil {
+			return nil, err
+		}
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		result = append(result, r)
+	}
+	return result, nil
 }
 
 // ===================== 主函数 =====================
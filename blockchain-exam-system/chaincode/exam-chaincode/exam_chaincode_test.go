@@ -0,0 +1,2412 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ===================== 测试工具 =====================
+
+// testIdentity 测试用客户端身份，证书属性取自 attrs
+type testIdentity struct {
+	id    string
+	mspID string
+	attrs map[string]string
+}
+
+func (i *testIdentity) GetID() (string, error) {
+	return i.id, nil
+}
+
+func (i *testIdentity) GetMSPID() (string, error) {
+	return i.mspID, nil
+}
+
+func (i *testIdentity) GetAttributeValue(name string) (string, bool, error) {
+	value, found := i.attrs[name]
+	return value, found, nil
+}
+
+func (i *testIdentity) AssertAttributeValue(name string, value string) error {
+	if i.attrs[name] != value {
+		return fmt.Errorf("attribute %s is not %s", name, value)
+	}
+	return nil
+}
+
+func (i *testIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
+// richQueryStub 在 MockStub 上模拟 CouchDB 富查询：对全部 JSON 文档求值 selector（等值、$exists、$gt、$in），
+// 和 CouchDB 一样不区分文档类型；遇到其他操作符时报告不支持，使链码退化为范围扫描
+type richQueryStub struct {
+	*shimtest.MockStub
+}
+
+func (s *richQueryStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	var parsed struct {
+		Selector map[string]interface{} `json:"selector"`
+	}
+	if err := json.Unmarshal([]byte(query), &parsed); err != nil {
+		return nil, err
+	}
+
+	iterator := &sliceIterator{}
+	for elem := s.Keys.Front(); elem != nil; elem = elem.Next() {
+		key := elem.Value.(string)
+		value := s.State[key]
+
+		var doc map[string]interface{}
+		if err := json.Unmarshal(value, &doc); err != nil {
+			continue
+		}
+		matched, err := matchDocument(doc, parsed.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			iterator.results = append(iterator.results, &queryresult.KV{Key: key, Value: value})
+		}
+	}
+	return iterator, nil
+}
+
+// GetQueryResultWithPagination 在 GetQueryResult 的结果上分页，书签为上一页最后一个键的 base64url 编码
+func (s *richQueryStub) GetQueryResultWithPagination(
+	query string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	after, err := base64.RawURLEncoding.DecodeString(bookmark)
+	if err != nil {
+		return nil, nil, fmt.Errorf("test stub received an undecodable bookmark: %v", err)
+	}
+
+	all, err := s.GetQueryResult(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page := &sliceIterator{}
+	for _, result := range all.(*sliceIterator).results {
+		if bookmark != "" && result.Key <= string(after) {
+			continue
+		}
+		if int32(len(page.results)) == pageSize {
+			break
+		}
+		page.results = append(page.results, result)
+	}
+
+	metadata := &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(page.results)), Bookmark: bookmark}
+	if len(page.results) > 0 {
+		metadata.Bookmark = base64.RawURLEncoding.EncodeToString([]byte(page.results[len(page.results)-1].Key))
+	}
+	return page, metadata, nil
+}
+
+// GetStateByPartialCompositeKeyWithPagination 按 Fabric 的语义分页：书签为下一页的起始键，读完时为空
+func (s *richQueryStub) GetStateByPartialCompositeKeyWithPagination(
+	objectType string,
+	keys []string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page := &sliceIterator{}
+	metadata := &pb.QueryResponseMetadata{}
+	for elem := s.Keys.Front(); elem != nil; elem = elem.Next() {
+		key := elem.Value.(string)
+		if !strings.HasPrefix(key, prefix) || (bookmark != "" && key < bookmark) {
+			continue
+		}
+		if int32(len(page.results)) == pageSize {
+			metadata.Bookmark = key
+			break
+		}
+		page.results = append(page.results, &queryresult.KV{Key: key, Value: s.State[key]})
+	}
+	metadata.FetchedRecordsCount = int32(len(page.results))
+	return page, metadata, nil
+}
+
+// matchDocument 对单个 JSON 文档求值 selector
+func matchDocument(doc map[string]interface{}, selector map[string]interface{}) (bool, error) {
+	for field, condition := range selector {
+		value, present := doc[field]
+
+		operators, ok := condition.(map[string]interface{})
+		if !ok {
+			if !present || !reflect.DeepEqual(value, condition) {
+				return false, nil
+			}
+			continue
+		}
+
+		for operator, operand := range operators {
+			switch operator {
+			case "$exists":
+				if present != operand.(bool) {
+					return false, nil
+				}
+			case "$gt":
+				str, ok := value.(string)
+				if !present || !ok || str <= operand.(string) {
+					return false, nil
+				}
+			case "$in":
+				found := false
+				for _, candidate := range operand.([]interface{}) {
+					if present && reflect.DeepEqual(value, candidate) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					return false, nil
+				}
+			default:
+				return false, fmt.Errorf("operator %s not supported by the test stub", operator)
+			}
+		}
+	}
+	return true, nil
+}
+
+// sliceIterator 基于切片的状态查询迭代器
+type sliceIterator struct {
+	results []*queryresult.KV
+	pos     int
+}
+
+func (it *sliceIterator) HasNext() bool {
+	return it.pos < len(it.results)
+}
+
+func (it *sliceIterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, fmt.Errorf("iterator exhausted")
+	}
+	result := it.results[it.pos]
+	it.pos++
+	return result, nil
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
+
+// testEnv 单个测试用例的链码、账本和调用者
+type testEnv struct {
+	t     *testing.T
+	cc    *ExamPaperContract
+	stub  *richQueryStub
+	ctx   *contractapi.TransactionContext
+	txSeq int
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	stub := &richQueryStub{MockStub: shimtest.NewMockStub("exam-chaincode", nil)}
+	ctx := &contractapi.TransactionContext{}
+	ctx.SetStub(stub)
+
+	env := &testEnv{t: t, cc: &ExamPaperContract{}, stub: stub, ctx: ctx}
+	env.as("admin01", "admin")
+	return env
+}
+
+// as 以指定用户和角色开始一笔新交易，role 为空时证书不带 role 属性
+func (e *testEnv) as(userID string, role string) contractapi.TransactionContextInterface {
+	e.txSeq++
+	e.stub.MockTransactionStart(testFileHash(fmt.Sprintf("tx%d", e.txSeq)))
+
+	attrs := map[string]string{"hf.EnrollmentID": userID}
+	if role != "" {
+		attrs["role"] = role
+	}
+	e.ctx.SetClientIdentity(&testIdentity{id: "x509::CN=" + userID, mspID: "Org1MSP", attrs: attrs})
+	return e.ctx
+}
+
+// at 将当前交易时间设为 when
+func (e *testEnv) at(when time.Time) {
+	e.stub.TxTimestamp = timestamppb.New(when)
+}
+
+// testFileHash 按试卷ID生成互不相同的 SHA256 文件哈希
+func testFileHash(paperID string) string {
+	sum := sha256.Sum256([]byte(paperID))
+	return hex.EncodeToString(sum[:])
+}
+
+// testIPFSHash 按试卷ID生成格式合法的 CID
+func testIPFSHash(paperID string) string {
+	sum := testFileHash("ipfs:" + paperID)
+	return "Qm" + sum[:44]
+}
+
+// storePaper 以教师身份存储试卷，解锁时间为 unlockTime
+func (e *testEnv) storePaper(paperID string, examID string, unlockTime time.Time, optionsJSON string) {
+	e.t.Helper()
+	ctx := e.as("teacher01", "teacher")
+	err := e.cc.StorePaperWithOptions(ctx, paperID, examID, "数学", testIPFSHash(paperID), testFileHash(paperID),
+		unlockTime.UTC().Format(time.RFC3339), "teacher01", optionsJSON)
+	if err != nil {
+		e.t.Fatalf("StorePaper(%s) failed: %v", paperID, err)
+	}
+}
+
+// getPaper 直接读取账本中的试卷记录
+func (e *testEnv) getPaper(paperID string) *ExamPaper {
+	e.t.Helper()
+	paperJSON := e.stub.State[paperID]
+	if paperJSON == nil {
+		e.t.Fatalf("paper %s does not exist", paperID)
+	}
+	var paper ExamPaper
+	if err := json.Unmarshal(paperJSON, &paper); err != nil {
+		e.t.Fatalf("failed to unmarshal paper %s: %v", paperID, err)
+	}
+	return &paper
+}
+
+// setPaper 直接改写账本中的试卷记录，用于构造测试所需的状态
+func (e *testEnv) setPaper(paperID string, mutate func(paper *ExamPaper)) {
+	e.t.Helper()
+	paper := e.getPaper(paperID)
+	mutate(paper)
+	paperJSON, err := json.Marshal(paper)
+	if err != nil {
+		e.t.Fatalf("failed to marshal paper %s: %v", paperID, err)
+	}
+	e.stub.State[paperID] = paperJSON
+}
+
+// expectError 校验 err 与期望的错误片段一致，wantErr 为空表示期望成功
+func expectError(t *testing.T, err error, wantErr string) {
+	t.Helper()
+	if wantErr == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Fatalf("expected error containing %q, got %v", wantErr, err)
+	}
+}
+
+// ===================== 考试登记 =====================
+
+func TestReconcileExamNamesSkipsRegistryEntry(t *testing.T) {
+	env := newTestEnv(t)
+	unlock := time.Now().Add(24 * time.Hour)
+
+	if err := env.cc.RegisterExam(env.as("admin01", "admin"), "EXAM1", "期末考试", "admin01"); err != nil {
+		t.Fatalf("RegisterExam failed: %v", err)
+	}
+	env.storePaper("P1", "EXAM1", unlock, `{"exam_name":"旧名称"}`)
+	env.storePaper("P2", "EXAM1", unlock, `{"exam_name":"旧名称"}`)
+	env.storePaper("P3", "EXAM2", unlock, `{"exam_name":"旧名称"}`)
+
+	tests := []struct {
+		name        string
+		examID      string
+		wantUpdated int
+		wantErr     string
+	}{
+		{name: "registered exam", examID: "EXAM1", wantUpdated: 2},
+		{name: "already reconciled", examID: "EXAM1", wantUpdated: 0},
+		{name: "unregistered exam", examID: "EXAM2", wantErr: "exam EXAM2 does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := env.cc.ReconcileExamNames(env.as("admin01", "admin"), tt.examID)
+			expectError(t, err, tt.wantErr)
+			if updated != tt.wantUpdated {
+				t.Fatalf("expected %d updated papers, got %d", tt.wantUpdated, updated)
+			}
+		})
+	}
+
+	for _, paperID := range []string{"P1", "P2"} {
+		if name := env.getPaper(paperID).ExamName; name != "期末考试" {
+			t.Fatalf("paper %s exam name is %q, want 期末考试", paperID, name)
+		}
+	}
+	if env.getPaper("P3").ExamName != "旧名称" {
+		t.Fatalf("paper P3 of another exam must not be touched")
+	}
+	if _, ok := env.stub.State[""]; ok {
+		t.Fatalf("registry entry must not be written back as a paper")
+	}
+}
+
+// ===================== 访问日志 =====================
+
+func TestGetExamAccessLogsPagesOnlyExamPapers(t *testing.T) {
+	env := newTestEnv(t)
+	unlock := time.Now().Add(24 * time.Hour)
+
+	if err := env.cc.RegisterExam(env.as("admin01", "admin"), "EXAM1", "期末考试", "admin01"); err != nil {
+		t.Fatalf("RegisterExam failed: %v", err)
+	}
+	env.storePaper("P1", "EXAM1", unlock, "")
+	env.storePaper("P2", "EXAM1", unlock, "")
+	env.storePaper("P3", "EXAM2", unlock, "")
+
+	for _, paperID := range []string{"P1", "P1", "P2", "P3"} {
+		if err := env.cc.RecordAccess(env.as("teacher01", "teacher"), paperID, "teacher01", "view", "10.0.0.1", ""); err != nil {
+			t.Fatalf("RecordAccess(%s) failed: %v", paperID, err)
+		}
+	}
+	// 不属于任何试卷的孤立日志（试卷ID为空）不能出现在考试日志中
+	if err := env.cc.ForceRecordAccess(env.as("admin01", "admin"), "", "admin01", "emergency", "10.0.0.1", ""); err != nil {
+		t.Fatalf("ForceRecordAccess failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		pageSize  int32
+		wantPages int
+	}{
+		{name: "single page", pageSize: 10, wantPages: 1},
+		{name: "one log per page", pageSize: 1, wantPages: 3},
+		{name: "two logs per page", pageSize: 2, wantPages: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := env.as("admin01", "admin")
+			counts := map[string]int{}
+			pages := 0
+			bookmark := ""
+			for {
+				page, err := env.cc.GetExamAccessLogs(ctx, "EXAM1", tt.pageSize, bookmark)
+				if err != nil {
+					t.Fatalf("GetExamAccessLogs failed: %v", err)
+				}
+				pages++
+				for _, log := range page.Logs {
+					counts[log.PaperID]++
+				}
+				if !page.HasMore {
+					break
+				}
+				bookmark = page.Bookmark
+			}
+
+			if pages != tt.wantPages {
+				t.Fatalf("expected %d pages, got %d", tt.wantPages, pages)
+			}
+			if want := map[string]int{"P1": 2, "P2": 1}; !reflect.DeepEqual(counts, want) {
+				t.Fatalf("expected logs %v, got %v", want, counts)
+			}
+		})
+	}
+}
+
+// ===================== 答案承诺 =====================
+
+func TestStoreAnswerKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    string
+		mutate  func(paper *ExamPaper)
+		wantErr string
+	}{
+		{name: "teacher", role: "teacher"},
+		{name: "coe", role: "coe"},
+		{name: "student", role: "student", wantErr: "is not permitted"},
+		{name: "no role", role: "", wantErr: "is not permitted"},
+		{
+			name:    "archived",
+			role:    "teacher",
+			mutate:  func(paper *ExamPaper) { paper.Status = "archived" },
+			wantErr: "is archived",
+		},
+		{
+			name:    "published",
+			role:    "teacher",
+			mutate:  func(paper *ExamPaper) { paper.Status = "archived"; paper.Published = true },
+			wantErr: "is published",
+		},
+		{
+			name:    "frozen",
+			role:    "teacher",
+			mutate:  func(paper *ExamPaper) { paper.Frozen = true; paper.FrozenCaseRef = "CASE1" },
+			wantErr: "is frozen",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", time.Now().Add(24*time.Hour), "")
+			if tt.mutate != nil {
+				env.setPaper("P1", tt.mutate)
+			}
+
+			err := env.cc.StoreAnswerKey(env.as("teacher01", tt.role), "P1", "commitment-1", "teacher01")
+			expectError(t, err, tt.wantErr)
+
+			paper := env.getPaper("P1")
+			if tt.wantErr == "" {
+				if paper.AnswerKeyCommitment != "commitment-1" || paper.AnswerKeyVersion != 1 {
+					t.Fatalf("expected commitment version 1, got %q version %d", paper.AnswerKeyCommitment, paper.AnswerKeyVersion)
+				}
+				return
+			}
+			if paper.AnswerKeyCommitment != "" || paper.AnswerKeyVersion != 0 {
+				t.Fatalf("rejected call must not change the commitment")
+			}
+			iterator, err := env.stub.GetStateByPartialCompositeKey(answerKeyVersionKeyType, []string{"P1"})
+			if err != nil {
+				t.Fatalf("failed to list versions: %v", err)
+			}
+			if iterator.HasNext() {
+				t.Fatalf("rejected call must not write a version record")
+			}
+		})
+	}
+}
+
+func TestVerifyAnswerKeyCommitmentsSkipsRegistryEntry(t *testing.T) {
+	env := newTestEnv(t)
+	unlock := time.Now().Add(24 * time.Hour)
+
+	if err := env.cc.RegisterExam(env.as("admin01", "admin"), "EXAM1", "期末考试", "admin01"); err != nil {
+		t.Fatalf("RegisterExam failed: %v", err)
+	}
+	env.storePaper("P1", "EXAM1", unlock, "")
+	env.storePaper("P2", "EXAM1", unlock, "")
+	if err := env.cc.StoreAnswerKey(env.as("teacher01", "teacher"), "P1", "commitment-1", "teacher01"); err != nil {
+		t.Fatalf("StoreAnswerKey failed: %v", err)
+	}
+
+	report, err := env.cc.VerifyAnswerKeyCommitments(env.as("admin01", "admin"), "EXAM1", `{"P1":"commitment-1"}`)
+	if err != nil {
+		t.Fatalf("VerifyAnswerKeyCommitments failed: %v", err)
+	}
+	if !reflect.DeepEqual(report.Matched, []string{"P1"}) {
+		t.Fatalf("expected P1 matched, got %v", report.Matched)
+	}
+	if !reflect.DeepEqual(report.NoCommitment, []string{"P2"}) {
+		t.Fatalf("expected only P2 without commitment, got %v", report.NoCommitment)
+	}
+}
+
+func TestVerifyAnswerKeyCommitmentsSealedBeforeRelease(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name        string
+		role        string
+		at          time.Time
+		guess       string
+		published   bool
+		wantMatched bool
+		wantSealed  bool
+	}{
+		{name: "student correct guess before release", role: "student", at: unlock.Add(-time.Minute), guess: "commitment-1", wantSealed: true},
+		{name: "student wrong guess before release", role: "student", at: unlock.Add(-time.Minute), guess: "guess", wantSealed: true},
+		{name: "student after release", role: "student", at: unlock, guess: "commitment-1", wantMatched: true},
+		{name: "teacher before release", role: "teacher", at: unlock.Add(-time.Minute), guess: "commitment-1", wantSealed: true},
+		{name: "admin before release", role: "admin", at: unlock.Add(-time.Minute), guess: "commitment-1", wantMatched: true},
+		{name: "published before release", role: "student", at: unlock.Add(-time.Minute), guess: "commitment-1", published: true, wantMatched: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			if err := env.cc.StoreAnswerKey(env.as("teacher01", "teacher"), "P1", "commitment-1", "teacher01"); err != nil {
+				t.Fatalf("StoreAnswerKey failed: %v", err)
+			}
+			if tt.published {
+				env.setPaper("P1", func(paper *ExamPaper) { paper.Published = true })
+			}
+
+			ctx := env.as("user01", tt.role)
+			env.at(tt.at)
+			report, err := env.cc.VerifyAnswerKeyCommitments(ctx, "EXAM1", fmt.Sprintf(`{"P1":%q}`, tt.guess))
+			if err != nil {
+				t.Fatalf("VerifyAnswerKeyCommitments failed: %v", err)
+			}
+			if matched := len(report.Matched) == 1; matched != tt.wantMatched {
+				t.Fatalf("expected matched=%v, got report %+v", tt.wantMatched, report)
+			}
+			if sealed := reflect.DeepEqual(report.NoCommitment, []string{"P1"}); sealed != tt.wantSealed {
+				t.Fatalf("expected sealed=%v, got report %+v", tt.wantSealed, report)
+			}
+			if tt.wantSealed && len(report.Mismatched) != 0 {
+				t.Fatalf("sealed commitments must not report mismatches, got %v", report.Mismatched)
+			}
+		})
+	}
+}
+
+func TestGetPapersMissingAnswerKeyOnlyListsPapers(t *testing.T) {
+	env := newTestEnv(t)
+	unlock := time.Now().Add(24 * time.Hour)
+
+	if err := env.cc.RegisterExam(env.as("admin01", "admin"), "EXAM1", "期末考试", "admin01"); err != nil {
+		t.Fatalf("RegisterExam failed: %v", err)
+	}
+	validUntil := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if _, err := env.cc.IssueUploadToken(env.as("coe01", "coe"), "EXAM1", validUntil); err != nil {
+		t.Fatalf("IssueUploadToken failed: %v", err)
+	}
+	env.storePaper("P1", "EXAM1", unlock, "")
+	env.storePaper("P2", "EXAM1", unlock, "")
+	env.storePaper("P3", "EXAM1", unlock, "")
+	env.setPaper("P2", func(paper *ExamPaper) { paper.Status = "unlocked" })
+	if err := env.cc.StoreAnswerKey(env.as("teacher01", "teacher"), "P3", "commitment-3", "teacher01"); err != nil {
+		t.Fatalf("StoreAnswerKey failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		examID string
+		want   []*MissingAnswerKey
+	}{
+		{
+			name:   "exam with registry entry and upload token",
+			examID: "EXAM1",
+			want: []*MissingAnswerKey{
+				{PaperID: "P1", Status: "locked"},
+				{PaperID: "P2", Status: "unlocked"},
+			},
+		},
+		{name: "unknown exam", examID: "EXAM2", want: []*MissingAnswerKey{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, err := env.cc.GetPapersMissingAnswerKey(env.as("admin01", "admin"), tt.examID)
+			if err != nil {
+				t.Fatalf("GetPapersMissingAnswerKey failed: %v", err)
+			}
+			if !reflect.DeepEqual(missing, tt.want) {
+				got, _ := json.Marshal(missing)
+				t.Fatalf("unexpected result %s", got)
+			}
+		})
+	}
+}
+
+func TestGetAnswerKeyVersionsRedaction(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		userID    string
+		role      string
+		at        time.Time
+		mutate    func(paper *ExamPaper)
+		wantShown bool
+	}{
+		{name: "student before release", userID: "student01", role: "student", at: unlock.Add(-time.Minute)},
+		{name: "student at release", userID: "student01", role: "student", at: unlock, wantShown: true},
+		{name: "student after release", userID: "student01", role: "student", at: unlock.Add(time.Hour), wantShown: true},
+		{name: "admin before release", userID: "admin01", role: "admin", at: unlock.Add(-time.Minute), wantShown: true},
+		{
+			name:      "published before release",
+			userID:    "student01",
+			role:      "student",
+			at:        unlock.Add(-time.Minute),
+			mutate:    func(paper *ExamPaper) { paper.Published = true },
+			wantShown: true,
+		},
+		{
+			name:   "viewer list excludes caller after release",
+			userID: "student01",
+			role:   "student",
+			at:     unlock.Add(time.Hour),
+			mutate: func(paper *ExamPaper) { paper.AllowedViewers = []string{"student02"} },
+		},
+		{
+			name:   "preview hidden before release",
+			userID: "student01",
+			role:   "student",
+			at:     unlock.Add(-time.Minute),
+			mutate: func(paper *ExamPaper) { paper.PreviewIPFSHash = testIPFSHash("preview") },
+		},
+		{
+			name:      "unreleased preview after release",
+			userID:    "student01",
+			role:      "student",
+			at:        unlock.Add(time.Hour),
+			mutate:    func(paper *ExamPaper) { paper.PreviewIPFSHash = testIPFSHash("preview") },
+			wantShown: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			for _, commitment := range []string{"commitment-1", "commitment-2"} {
+				if err := env.cc.StoreAnswerKey(env.as("teacher01", "teacher"), "P1", commitment, "teacher01"); err != nil {
+					t.Fatalf("StoreAnswerKey failed: %v", err)
+				}
+			}
+			if tt.mutate != nil {
+				env.setPaper("P1", tt.mutate)
+			}
+
+			ctx := env.as(tt.userID, tt.role)
+			env.at(tt.at)
+			versions, err := env.cc.GetAnswerKeyVersions(ctx, "P1")
+			if err != nil {
+				t.Fatalf("GetAnswerKeyVersions failed: %v", err)
+			}
+			if len(versions) != 2 {
+				t.Fatalf("expected 2 versions, got %d", len(versions))
+			}
+			for i, version := range versions {
+				want := ""
+				if tt.wantShown {
+					want = fmt.Sprintf("commitment-%d", i+1)
+				}
+				if version.Version != i+1 || version.Commitment != want {
+					t.Fatalf("version %d: expected commitment %q, got version %d commitment %q", i+1, want, version.Version, version.Commitment)
+				}
+			}
+		})
+	}
+}
+
+func TestAnswerKeyUnlockTimeIndependentOfPaper(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	answerKeyUnlock := unlock.Add(2 * time.Hour)
+
+	tests := []struct {
+		name         string
+		at           time.Time
+		wantReleased bool
+	}{
+		{name: "before paper unlock", at: unlock.Add(-time.Minute)},
+		{name: "paper unlocked, answer key sealed", at: unlock.Add(time.Hour)},
+		{name: "answer key released", at: answerKeyUnlock, wantReleased: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			if err := env.cc.StoreAnswerKey(env.as("teacher01", "teacher"), "P1", "commitment-1", "teacher01"); err != nil {
+				t.Fatalf("StoreAnswerKey failed: %v", err)
+			}
+			err := env.cc.SetAnswerKeyUnlockTime(env.as("teacher01", "teacher"), "P1", answerKeyUnlock.Format(time.RFC3339))
+			if err != nil {
+				t.Fatalf("SetAnswerKeyUnlockTime failed: %v", err)
+			}
+
+			ctx := env.as("student01", "student")
+			env.at(tt.at)
+
+			released, err := env.cc.CheckAnswerKeyUnlockTime(ctx, "P1")
+			if err != nil {
+				t.Fatalf("CheckAnswerKeyUnlockTime failed: %v", err)
+			}
+			if released != tt.wantReleased {
+				t.Fatalf("expected released %v, got %v", tt.wantReleased, released)
+			}
+
+			key, err := env.cc.GetAnswerKey(ctx, "P1")
+			if tt.wantReleased {
+				expectError(t, err, "")
+				if key.Commitment != "commitment-1" {
+					t.Fatalf("expected commitment-1, got %q", key.Commitment)
+				}
+			} else {
+				expectError(t, err, "is sealed until")
+			}
+
+			versions, err := env.cc.GetAnswerKeyVersions(ctx, "P1")
+			if err != nil {
+				t.Fatalf("GetAnswerKeyVersions failed: %v", err)
+			}
+			if shown := versions[0].Commitment != ""; shown != tt.wantReleased {
+				t.Fatalf("expected version commitment shown %v, got %q", tt.wantReleased, versions[0].Commitment)
+			}
+
+			paper, err := env.cc.GetPaper(ctx, "P1")
+			if err != nil {
+				t.Fatalf("GetPaper failed: %v", err)
+			}
+			if shown := paper.AnswerKeyCommitment != ""; shown != tt.wantReleased {
+				t.Fatalf("expected paper commitment shown %v, got %q", tt.wantReleased, paper.AnswerKeyCommitment)
+			}
+		})
+	}
+}
+
+// ===================== 参数校验 =====================
+
+func TestValidateFileHash(t *testing.T) {
+	sha256Hex := testFileHash("P1")
+	sha512Hex := sha256Hex + sha256Hex
+
+	tests := []struct {
+		name    string
+		algo    string
+		hash    string
+		wantErr string
+	}{
+		{name: "SM3 hex", algo: "SM3", hash: sha256Hex},
+		{name: "SM3 uppercase hex", algo: "SM3", hash: strings.ToUpper(sha256Hex)},
+		{name: "SHA256 hex", algo: "SHA256", hash: sha256Hex},
+		{name: "SHA512 hex", algo: "SHA512", hash: sha512Hex},
+		{name: "SM3 too short", algo: "SM3", hash: sha256Hex[:40], wantErr: "expected 64 hex characters, got 40"},
+		{name: "SHA256 given SHA512 digest", algo: "SHA256", hash: sha512Hex, wantErr: "expected 64 hex characters, got 128"},
+		{name: "SHA512 given SHA256 digest", algo: "SHA512", hash: sha256Hex, wantErr: "expected 128 hex characters, got 64"},
+		{name: "SM3 base64", algo: "SM3", hash: "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=" + strings.Repeat("A", 20), wantErr: "must be hex encoded"},
+		{name: "SHA256 non-hex", algo: "SHA256", hash: strings.Repeat("z", 64), wantErr: "must be hex encoded"},
+		{name: "unsupported algorithm", algo: "MD5", hash: sha256Hex[:32], wantErr: "unsupported hash algorithm: MD5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, validateFileHash(tt.algo, tt.hash), tt.wantErr)
+		})
+	}
+}
+
+func TestStorePaperRejectsMalformedFileHash(t *testing.T) {
+	sha256Hex := testFileHash("P1")
+
+	tests := []struct {
+		name     string
+		fileHash string
+		options  string
+		wantErr  string
+		wantAlgo string
+	}{
+		{name: "default SM3", fileHash: sha256Hex, wantAlgo: "SM3"},
+		{name: "normalized algorithm name", fileHash: sha256Hex, options: `{"hash_algo":"sha-256"}`, wantAlgo: "SHA256"},
+		{name: "SHA512 digest length", fileHash: sha256Hex, options: `{"hash_algo":"SHA512"}`, wantErr: "invalid SHA512 file hash"},
+		{name: "base64 digest", fileHash: "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=", wantErr: "invalid SM3 file hash"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := env.as("teacher01", "teacher")
+			unlock := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+			err := env.cc.StorePaperWithOptions(ctx, "P1", "EXAM1", "数学", testIPFSHash("P1"), tt.fileHash, unlock, "teacher01", tt.options)
+			expectError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				if _, ok := env.stub.State["P1"]; ok {
+					t.Fatalf("rejected paper must not be stored")
+				}
+				return
+			}
+			if algo := env.getPaper("P1").HashAlgo; algo != tt.wantAlgo {
+				t.Fatalf("expected hash algorithm %s, got %s", tt.wantAlgo, algo)
+			}
+		})
+	}
+}
+
+func TestGetAllPapersBookmarkValidation(t *testing.T) {
+	env := newTestEnv(t)
+	unlock := time.Now().Add(24 * time.Hour)
+	for _, paperID := range []string{"P1", "P2", "P3"} {
+		env.storePaper(paperID, "EXAM1", unlock, "")
+	}
+
+	first, err := env.cc.GetAllPapers(env.as("admin01", "admin"), 2, "")
+	if err != nil {
+		t.Fatalf("GetAllPapers failed: %v", err)
+	}
+	if len(first.Papers) != 2 || first.Bookmark == "" {
+		t.Fatalf("expected a first page of 2 papers with a bookmark, got %d papers", len(first.Papers))
+	}
+
+	tests := []struct {
+		name      string
+		bookmark  string
+		wantCount int
+		wantErr   string
+	}{
+		{name: "empty bookmark starts from the beginning", bookmark: "", wantCount: 2},
+		{name: "bookmark from previous page", bookmark: first.Bookmark, wantCount: 1},
+		{name: "garbage bookmark", bookmark: "not a bookmark!", wantErr: "use the bookmark returned by the previous page"},
+		{name: "padded base64 bookmark", bookmark: "UDE=", wantErr: "invalid bookmark"},
+		{name: "oversized bookmark", bookmark: strings.Repeat("A", maxBookmarkLength+1), wantErr: "longer than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := env.cc.GetAllPapers(env.as("admin01", "admin"), 2, tt.bookmark)
+			expectError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+			if len(page.Papers) != tt.wantCount {
+				t.Fatalf("expected %d papers, got %d", tt.wantCount, len(page.Papers))
+			}
+		})
+	}
+}
+
+// ===================== 不可变字段 =====================
+
+func TestExamIDAndIdentityFieldsAreImmutable(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour)
+	unlockText := unlock.UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name    string
+		call    func(env *testEnv) error
+		wantErr string
+	}{
+		{
+			name: "patch exam_id",
+			call: func(env *testEnv) error {
+				return env.cc.PatchPaper(env.as("teacher01", "teacher"), "P1", `{"exam_id":"EXAM2"}`, 1, "teacher01")
+			},
+			wantErr: "field exam_id is immutable",
+		},
+		{
+			name: "patch paper_id",
+			call: func(env *testEnv) error {
+				return env.cc.PatchPaper(env.as("teacher01", "teacher"), "P1", `{"paper_id":"P9"}`, 1, "teacher01")
+			},
+			wantErr: "field paper_id is immutable",
+		},
+		{
+			name: "patch created_at",
+			call: func(env *testEnv) error {
+				return env.cc.PatchPaper(env.as("teacher01", "teacher"), "P1", `{"created_at":"2020-01-01T00:00:00Z"}`, 1, "teacher01")
+			},
+			wantErr: "field created_at is immutable",
+		},
+		{
+			name: "patch uploaded_by",
+			call: func(env *testEnv) error {
+				return env.cc.PatchPaper(env.as("teacher01", "teacher"), "P1", `{"uploaded_by":"teacher02"}`, 1, "teacher01")
+			},
+			wantErr: "field uploaded_by is immutable",
+		},
+		{
+			name: "store again under another exam",
+			call: func(env *testEnv) error {
+				return env.cc.StorePaper(env.as("teacher01", "teacher"), "P1", "EXAM2", "数学", testIPFSHash("P1"), testFileHash("P1"), unlockText, "teacher01")
+			},
+			wantErr: "paper P1 already exists",
+		},
+		{
+			name: "fill reserved draft under another exam",
+			call: func(env *testEnv) error {
+				if err := env.cc.ReservePaperID(env.as("teacher01", "teacher"), "P2", "EXAM1", "teacher01"); err != nil {
+					return err
+				}
+				return env.cc.StorePaper(env.as("teacher01", "teacher"), "P2", "EXAM2", "数学", testIPFSHash("P2"), testFileHash("P2"), unlockText, "teacher01")
+			},
+			wantErr: "paper P2 is reserved for exam EXAM1",
+		},
+		{
+			name: "internal write with a changed exam_id",
+			call: func(env *testEnv) error {
+				env.as("admin01", "admin")
+				paper := env.getPaper("P1")
+				paper.ExamID = "EXAM2"
+				return savePaper(env.ctx, paper)
+			},
+			wantErr: "field exam_id of paper P1 is immutable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+
+			expectError(t, tt.call(env), tt.wantErr)
+			if examID := env.getPaper("P1").ExamID; examID != "EXAM1" {
+				t.Fatalf("exam_id changed to %s", examID)
+			}
+		})
+	}
+}
+
+// ===================== 公开试卷 =====================
+
+func TestPublishedPapersCannotChangeStatus(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	afterUnlock := unlock.Add(time.Minute)
+
+	tests := []struct {
+		name   string
+		status string
+		call   func(env *testEnv) error
+	}{
+		{
+			name:   "update archived to locked",
+			status: "archived",
+			call: func(env *testEnv) error {
+				return env.cc.UpdatePaperStatus(env.as("admin01", "admin"), "P1", "locked")
+			},
+		},
+		{
+			name:   "update archived to unlocked",
+			status: "archived",
+			call: func(env *testEnv) error {
+				return env.cc.UpdatePaperStatus(env.as("admin01", "admin"), "P1", "unlocked")
+			},
+		},
+		{
+			name:   "update unlocked to archived",
+			status: "unlocked",
+			call: func(env *testEnv) error {
+				return env.cc.UpdatePaperStatus(env.as("admin01", "admin"), "P1", "archived")
+			},
+		},
+		{
+			name:   "relock",
+			status: "unlocked",
+			call: func(env *testEnv) error {
+				return env.cc.Relock(env.as("admin01", "admin"), "P1", unlock.Add(time.Hour).Format(time.RFC3339), "admin01")
+			},
+		},
+		{
+			name:   "unlock",
+			status: "locked",
+			call: func(env *testEnv) error {
+				ctx := env.as("coe01", "coe")
+				env.at(afterUnlock)
+				return env.cc.UnlockPaper(ctx, "P1", "coe01")
+			},
+		},
+		{
+			name:   "attempt unlock",
+			status: "locked",
+			call: func(env *testEnv) error {
+				ctx := env.as("coe01", "coe")
+				env.at(afterUnlock)
+				_, err := env.cc.AttemptUnlock(ctx, "P1", "coe01")
+				return err
+			},
+		},
+		{
+			name:   "trigger unlock check",
+			status: "locked",
+			call: func(env *testEnv) error {
+				ctx := env.as("coe01", "coe")
+				env.at(afterUnlock)
+				_, err := env.cc.TriggerUnlockCheck(ctx, "P1")
+				return err
+			},
+		},
+		{
+			name:   "unlock exam papers",
+			status: "locked",
+			call: func(env *testEnv) error {
+				ctx := env.as("coe01", "coe")
+				env.at(afterUnlock)
+				_, err := env.cc.UnlockExamPapers(ctx, "EXAM1")
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			// 非 archived 的公开记录只能来自异常写入，用于确认公开标记本身就是终态
+			env.setPaper("P1", func(paper *ExamPaper) {
+				paper.Status = tt.status
+				paper.Published = true
+			})
+
+			expectError(t, tt.call(env), "is published")
+
+			paper := env.getPaper("P1")
+			if paper.Status != tt.status || !paper.Published {
+				t.Fatalf("published paper changed to status %s, published %v", paper.Status, paper.Published)
+			}
+		})
+	}
+}
+
+func TestValidateIPFSHash(t *testing.T) {
+	cid := testIPFSHash("P1")
+
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr string
+	}{
+		{name: "bare CID", hash: cid},
+		{name: "gateway URL", hash: "https://ipfs.io/ipfs/" + cid, wantErr: "store the bare CID only"},
+		{name: "ipfs scheme", hash: "ipfs://" + cid, wantErr: "store the bare CID only"},
+		{name: "gateway path", hash: "/ipfs/" + cid, wantErr: "store the bare CID only"},
+		{name: "CID with file path", hash: cid + "/paper.pdf", wantErr: "store the bare CID only"},
+		{name: "too short", hash: cid[:20], wantErr: "invalid IPFS hash format"},
+		{name: "wrong prefix", hash: "Xm" + cid[2:], wantErr: "invalid IPFS hash format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, validateIPFSHash(tt.hash), tt.wantErr)
+		})
+	}
+}
+
+func TestStorePaperRejectsGatewayURL(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.as("teacher01", "teacher")
+	unlock := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	err := env.cc.StorePaper(ctx, "P1", "EXAM1", "数学", "https://gateway.example.com/ipfs/"+testIPFSHash("P1"), testFileHash("P1"), unlock, "teacher01")
+	expectError(t, err, "store the bare CID only")
+	if _, ok := env.stub.State["P1"]; ok {
+		t.Fatalf("paper with a URL-form IPFS hash must not be stored")
+	}
+}
+
+// ===================== 系统键 =====================
+
+func TestReservedPaperIDsAreRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		paperID string
+		wantErr string
+	}{
+		{name: "ordinary ID", paperID: "P1"},
+		{name: "prefix without underscore", paperID: "SYSTEM1"},
+		{name: "system prefix", paperID: "SYS_P1", wantErr: "uses the reserved prefix SYS_"},
+		{name: "config key", paperID: configKey, wantErr: "uses the reserved prefix SYS_"},
+		{name: "legacy config key", paperID: "ChaincodeConfig", wantErr: "is a reserved system key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unlock := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+			env := newTestEnv(t)
+			err := env.cc.StorePaper(env.as("teacher01", "teacher"), tt.paperID, "EXAM1", "数学",
+				testIPFSHash(tt.paperID), testFileHash(tt.paperID), unlock, "teacher01")
+			expectError(t, err, tt.wantErr)
+
+			env = newTestEnv(t)
+			err = env.cc.ReservePaperID(env.as("teacher01", "teacher"), tt.paperID, "EXAM1", "teacher01")
+			expectError(t, err, tt.wantErr)
+			if _, ok := env.stub.State[tt.paperID]; ok == (tt.wantErr != "") {
+				t.Fatalf("unexpected ledger state for %s after ReservePaperID", tt.paperID)
+			}
+		})
+	}
+}
+
+func TestConfigMigratesFromLegacyKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		legacy        string
+		current       string
+		wantRetention int64
+		wantValidity  int64
+	}{
+		{name: "no config", wantValidity: 60},
+		{name: "legacy config only", legacy: `{"retention_seconds":100}`, wantRetention: 100, wantValidity: 60},
+		{
+			name:          "current config wins over legacy",
+			legacy:        `{"retention_seconds":100}`,
+			current:       `{"retention_seconds":300}`,
+			wantRetention: 300,
+			wantValidity:  60,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			if tt.legacy != "" {
+				if err := env.stub.PutState(legacyConfigKey, []byte(tt.legacy)); err != nil {
+					t.Fatalf("failed to seed legacy config: %v", err)
+				}
+			}
+			if tt.current != "" {
+				if err := env.stub.PutState(configKey, []byte(tt.current)); err != nil {
+					t.Fatalf("failed to seed config: %v", err)
+				}
+			}
+
+			if err := env.cc.SetApprovalValidity(env.as("admin01", "admin"), 60); err != nil {
+				t.Fatalf("SetApprovalValidity failed: %v", err)
+			}
+
+			if _, ok := env.stub.State[legacyConfigKey]; ok {
+				t.Fatalf("legacy config key must be removed on write")
+			}
+			var stored ChaincodeConfig
+			if err := json.Unmarshal(env.stub.State[configKey], &stored); err != nil {
+				t.Fatalf("config not stored under %s: %v", configKey, err)
+			}
+			if stored.RetentionSeconds != tt.wantRetention || stored.ApprovalValiditySeconds != tt.wantValidity {
+				t.Fatalf("expected retention %d validity %d, got retention %d validity %d",
+					tt.wantRetention, tt.wantValidity, stored.RetentionSeconds, stored.ApprovalValiditySeconds)
+			}
+		})
+	}
+}
+
+// ===================== 时间解析 =====================
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(2030, 6, 1, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		layout  string // 期望命中的 acceptedTimeLayouts 条目，拒绝的输入为空
+		value   string
+		want    time.Time
+		wantErr string
+	}{
+		{name: "RFC3339 UTC", layout: time.RFC3339, value: "2030-06-01T01:00:00Z", want: want},
+		{name: "RFC3339 with offset", layout: time.RFC3339, value: "2030-06-01T09:00:00+08:00", want: want},
+		{name: "RFC3339Nano", layout: time.RFC3339Nano, value: "2030-06-01T09:00:00.250+08:00", want: want.Add(250 * time.Millisecond)},
+		{name: "space separator with offset", layout: "2006-01-02 15:04:05Z07:00", value: "2030-06-01 09:00:00+08:00", want: want},
+		{name: "no offset read as UTC", layout: "2006-01-02T15:04:05", value: "2030-06-01T01:00:00", want: want},
+		{name: "space separator without offset read as UTC", layout: "2006-01-02 15:04:05", value: "2030-06-01 01:00:00", want: want},
+		{name: "surrounding whitespace", layout: time.RFC3339, value: "  2030-06-01T01:00:00Z\n", want: want},
+		{name: "slash date", value: "2030/06/01 01:00:00", wantErr: "accepted formats"},
+		{name: "date only", value: "2030-06-01", wantErr: "accepted formats"},
+		{name: "empty", value: "", wantErr: "cannot parse time"},
+	}
+
+	covered := map[string]bool{}
+	for _, tt := range tests {
+		covered[tt.layout] = true
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTime(tt.value)
+			expectError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Fatalf("expected %s in UTC, got %s", tt.want.Format(time.RFC3339Nano), got.Format(time.RFC3339Nano))
+			}
+		})
+	}
+
+	for _, layout := range acceptedTimeLayouts {
+		if !covered[layout] {
+			t.Errorf("accepted layout %q has no test case", layout)
+		}
+	}
+}
+
+func TestStorePaperNormalizesUnlockTime(t *testing.T) {
+	tests := []struct {
+		name       string
+		unlockTime string
+		want       string
+		wantErr    string
+	}{
+		{name: "offset converted to UTC", unlockTime: "2030-06-01T09:00:00+08:00", want: "2030-06-01T01:00:00Z"},
+		{name: "space separator without offset", unlockTime: "2030-06-01 01:00:00", want: "2030-06-01T01:00:00Z"},
+		{name: "unparseable", unlockTime: "06/01/2030", wantErr: "invalid unlock time"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			err := env.cc.StorePaper(env.as("teacher01", "teacher"), "P1", "EXAM1", "数学", testIPFSHash("P1"), testFileHash("P1"), tt.unlockTime, "teacher01")
+			expectError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+			if got := env.getPaper("P1").UnlockTime; got != tt.want {
+				t.Fatalf("expected unlock time %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTouchPaperClockSkewAcceptsTimeLayouts(t *testing.T) {
+	now := time.Date(2030, 6, 1, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		updatedAt   string
+		reject      bool
+		wantUpdated string
+		wantErr     string
+	}{
+		{name: "earlier RFC3339", updatedAt: "2030-06-01T00:00:00Z", wantUpdated: "2030-06-01T01:00:00Z"},
+		{name: "later RFC3339 clamped", updatedAt: "2030-06-01T02:00:00Z", wantUpdated: "2030-06-01T02:00:00Z"},
+		{name: "later space separator clamped", updatedAt: "2030-06-01 02:00:00", wantUpdated: "2030-06-01 02:00:00"},
+		{name: "later space separator with offset rejected", updatedAt: "2030-06-01 10:00:00+08:00", reject: true, wantErr: "is earlier than last update"},
+		{name: "later no offset rejected", updatedAt: "2030-06-01T02:00:00", reject: true, wantErr: "is earlier than last update"},
+		{name: "unparseable overwritten", updatedAt: "06/01/2030", reject: true, wantUpdated: "2030-06-01T01:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			if err := env.cc.SetRejectClockSkew(env.as("admin01", "admin"), tt.reject); err != nil {
+				t.Fatalf("SetRejectClockSkew failed: %v", err)
+			}
+
+			ctx := env.as("teacher01", "teacher")
+			env.at(now)
+			paper := &ExamPaper{PaperID: "P1", UpdatedAt: tt.updatedAt}
+			expectError(t, touchPaper(ctx, paper), tt.wantErr)
+			if tt.wantErr == "" && paper.UpdatedAt != tt.wantUpdated {
+				t.Fatalf("expected updated_at %s, got %s", tt.wantUpdated, paper.UpdatedAt)
+			}
+		})
+	}
+}
+
+func TestIssueUploadTokenAcceptsTimeLayouts(t *testing.T) {
+	now := time.Date(2030, 6, 1, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		validUntil string
+		want       string
+		wantErr    string
+	}{
+		{name: "RFC3339 with offset", validUntil: "2030-06-01T10:00:00+08:00", want: "2030-06-01T02:00:00Z"},
+		{name: "space separator without offset", validUntil: "2030-06-01 02:00:00", want: "2030-06-01T02:00:00Z"},
+		{name: "in the past", validUntil: "2030-06-01 00:00:00", wantErr: "must end in the future"},
+		{name: "unparseable", validUntil: "06/01/2030", wantErr: "failed to parse valid until time"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := env.as("coe01", "coe")
+			env.at(now)
+			tokenID, err := env.cc.IssueUploadToken(ctx, "EXAM1", tt.validUntil)
+			expectError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			token, err := env.cc.GetUploadToken(ctx, tokenID)
+			if err != nil {
+				t.Fatalf("GetUploadToken failed: %v", err)
+			}
+			if token.ValidUntil != tt.want {
+				t.Fatalf("expected valid until %s, got %s", tt.want, token.ValidUntil)
+			}
+		})
+	}
+}
+
+func TestRecordAccessAcceptsBackendActions(t *testing.T) {
+	env := newTestEnv(t)
+	env.storePaper("P1", "EXAM1", time.Now().Add(24*time.Hour), "")
+
+	// 与后端 PaperAccessLog.Action 的取值保持一致
+	tests := []struct {
+		action  string
+		wantErr string
+	}{
+		{action: "upload"},
+		{action: "encrypt"},
+		{action: "chain"},
+		{action: "view"},
+		{action: "download"},
+		{action: "decrypt"},
+		{action: "print", wantErr: `unknown action "print"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			err := env.cc.RecordAccess(env.as("teacher01", "teacher"), "P1", "teacher01", tt.action, "10.0.0.1", "")
+			expectError(t, err, tt.wantErr)
+		})
+	}
+}
+
+// dedupMarkers 返回试卷当前的去重记录键（去掉复合键前缀后的 userID/action/dedupKey）
+func (e *testEnv) dedupMarkers(paperID string) []string {
+	prefix := "\x00" + accessDedupKeyType + "\x00" + paperID + "\x00"
+	var markers []string
+	for key := range e.stub.State {
+		if strings.HasPrefix(key, prefix) {
+			markers = append(markers, strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(key, prefix), "\x00"), "\x00", "/"))
+		}
+	}
+	sort.Strings(markers)
+	return markers
+}
+
+// countAccessLogs 统计试卷指定动作的访问日志条数
+func (e *testEnv) countAccessLogs(paperID string, action string) int {
+	logs, err := e.cc.GetPaperAccessLogs(e.as("admin01", "admin"), paperID)
+	if err != nil {
+		e.t.Fatalf("GetPaperAccessLogs failed: %v", err)
+	}
+	count := 0
+	for _, log := range logs {
+		if log.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRecordAccessDedupExpiresMarkers(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+
+	type call struct {
+		offset   time.Duration
+		dedupKey string
+	}
+	tests := []struct {
+		name        string
+		calls       []call
+		wantLogs    int
+		wantMarkers []string
+	}{
+		{
+			name:        "retry within window is suppressed",
+			calls:       []call{{0, "req1"}, {30 * time.Second, "req1"}},
+			wantLogs:    1,
+			wantMarkers: []string{"student01/view/req1"},
+		},
+		{
+			name:        "retry after window is logged again",
+			calls:       []call{{0, "req1"}, {61 * time.Second, "req1"}},
+			wantLogs:    2,
+			wantMarkers: []string{"student01/view/req1"},
+		},
+		{
+			name:        "fresh marker for another request is kept",
+			calls:       []call{{0, "req1"}, {30 * time.Second, "req2"}},
+			wantLogs:    2,
+			wantMarkers: []string{"student01/view/req1", "student01/view/req2"},
+		},
+		{
+			name:        "stale marker for another request is cleared",
+			calls:       []call{{0, "req1"}, {61 * time.Second, "req2"}},
+			wantLogs:    2,
+			wantMarkers: []string{"student01/view/req2"},
+		},
+		{
+			name:        "no dedup key writes no marker",
+			calls:       []call{{0, ""}, {time.Second, ""}},
+			wantLogs:    2,
+			wantMarkers: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", start.Add(24*time.Hour), "")
+
+			for _, c := range tt.calls {
+				ctx := env.as("student01", "student")
+				env.at(start.Add(c.offset))
+				if err := env.cc.RecordAccessDedup(ctx, "P1", "student01", "view", "10.0.0.1", "", c.dedupKey); err != nil {
+					t.Fatalf("RecordAccessDedup failed: %v", err)
+				}
+			}
+
+			if got := env.countAccessLogs("P1", "view"); got != tt.wantLogs {
+				t.Fatalf("expected %d view logs, got %d", tt.wantLogs, got)
+			}
+			if got := env.dedupMarkers("P1"); !reflect.DeepEqual(got, tt.wantMarkers) {
+				t.Fatalf("expected markers %v, got %v", tt.wantMarkers, got)
+			}
+		})
+	}
+}
+
+func TestAccessDedupMarkersFollowPaper(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		apply   func(env *testEnv) error
+		wantOld []string
+		wantNew []string
+	}{
+		{
+			name: "rename moves markers",
+			apply: func(env *testEnv) error {
+				return env.cc.RenamePaper(env.as("teacher01", "teacher"), "P1", "P9", "teacher01")
+			},
+			wantNew: []string{"student01/view/req1"},
+		},
+		{
+			name: "delete clears markers",
+			apply: func(env *testEnv) error {
+				env.setPaper("P1", func(paper *ExamPaper) { paper.Status = "draft" })
+				return env.cc.DeletePaper(env.as("admin01", "admin"), "P1", "admin01")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			if err := env.cc.RecordAccessDedup(env.as("student01", "student"), "P1", "student01", "view", "", "", "req1"); err != nil {
+				t.Fatalf("RecordAccessDedup failed: %v", err)
+			}
+
+			if err := tt.apply(env); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := env.dedupMarkers("P1"); !reflect.DeepEqual(got, tt.wantOld) {
+				t.Fatalf("expected markers %v under P1, got %v", tt.wantOld, got)
+			}
+			if got := env.dedupMarkers("P9"); !reflect.DeepEqual(got, tt.wantNew) {
+				t.Fatalf("expected markers %v under P9, got %v", tt.wantNew, got)
+			}
+		})
+	}
+}
+
+// ===================== 学生视图 =====================
+
+func TestGetStudentViewEncryptionScheme(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name       string
+		options    string
+		status     string
+		legacy     bool
+		wantScheme string
+		wantErr    string
+	}{
+		{name: "default scheme", status: "unlocked", wantScheme: "SM4-CBC"},
+		{name: "declared scheme", options: `{"encryption_scheme":"aes-cbc"}`, status: "unlocked", wantScheme: "AES-CBC"},
+		{name: "paper stored before the field existed", status: "unlocked", legacy: true, wantScheme: "SM4-CBC"},
+		{name: "locked paper", status: "locked", wantErr: "only available once unlocked"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, tt.options)
+			env.setPaper("P1", func(paper *ExamPaper) {
+				paper.Status = tt.status
+				if tt.legacy {
+					paper.EncryptionScheme = ""
+				}
+			})
+
+			ctx := env.as("student01", "student")
+			env.at(unlock.Add(time.Minute))
+			view, err := env.cc.GetStudentView(ctx, "P1")
+			expectError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+			if view.EncryptionScheme != tt.wantScheme {
+				t.Fatalf("expected encryption scheme %s, got %s", tt.wantScheme, view.EncryptionScheme)
+			}
+			if view.IPFSHash != testIPFSHash("P1") || view.FileHash != testFileHash("P1") {
+				t.Fatalf("student view must carry the CID and file hash needed to decrypt")
+			}
+		})
+	}
+}
+
+// ===================== 委托上传 =====================
+
+func TestStorePaperUploadAuthorization(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	unlock := now.Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name        string
+		userID      string
+		role        string
+		gateways    string // 非空时先配置为后端网关身份
+		tokenExamID string // 非空时先为该考试签发令牌并在上传时使用
+		reuseToken  bool   // 令牌已被另一份试卷使用
+		at          time.Time
+		wantErr     string
+	}{
+		{name: "configured gateway without role", userID: "gateway", gateways: `["x509::CN=gateway"]`},
+		{name: "unconfigured identity without role", userID: "gateway", wantErr: "is not permitted"},
+		{name: "other identity than the gateway", userID: "intruder", gateways: `["x509::CN=gateway"]`, wantErr: "is not permitted"},
+		{name: "student without token", userID: "student01", role: "student", wantErr: "is not permitted"},
+		{name: "proctor without token", userID: "proctor01", role: "superintendent", wantErr: "is not permitted"},
+		{name: "teacher without token", userID: "teacher01", role: "teacher"},
+		{name: "proctor with token", userID: "proctor01", role: "superintendent", tokenExamID: "EXAM1"},
+		{name: "token already used", userID: "proctor01", role: "superintendent", tokenExamID: "EXAM1", reuseToken: true, wantErr: "has already been used"},
+		{name: "expired token", userID: "proctor01", role: "superintendent", tokenExamID: "EXAM1", at: now.Add(2 * time.Hour), wantErr: "expired at"},
+		{name: "token for another exam", userID: "proctor01", role: "superintendent", tokenExamID: "EXAM2", wantErr: "is not valid for exam EXAM1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			if tt.gateways != "" {
+				if err := env.cc.SetGatewayIdentities(env.as("admin01", "admin"), tt.gateways); err != nil {
+					t.Fatalf("SetGatewayIdentities failed: %v", err)
+				}
+			}
+
+			options := ""
+			if tt.tokenExamID != "" {
+				tokenID, err := env.cc.IssueUploadToken(env.as("coe01", "coe"), tt.tokenExamID, now.Add(time.Hour).UTC().Format(time.RFC3339))
+				if err != nil {
+					t.Fatalf("IssueUploadToken failed: %v", err)
+				}
+				options = fmt.Sprintf(`{"upload_token":%q}`, tokenID)
+				if tt.reuseToken {
+					err := env.cc.StorePaperWithOptions(env.as("proctor02", "superintendent"), "P0", tt.tokenExamID, "数学",
+						testIPFSHash("P0"), testFileHash("P0"), unlock, "teacher01", options)
+					if err != nil {
+						t.Fatalf("first redemption failed: %v", err)
+					}
+				}
+			}
+
+			ctx := env.as(tt.userID, tt.role)
+			if !tt.at.IsZero() {
+				env.at(tt.at)
+			}
+			err := env.cc.StorePaperWithOptions(ctx, "P1", "EXAM1", "数学", testIPFSHash("P1"), testFileHash("P1"), unlock, "teacher01", options)
+			expectError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			paper := env.getPaper("P1")
+			if tt.tokenExamID == "" {
+				if paper.UploadToken != "" {
+					t.Fatalf("upload without token must not record a token")
+				}
+				return
+			}
+			if paper.UploadToken == "" || paper.TokenRedeemedBy != "x509::CN="+tt.userID {
+				t.Fatalf("expected token redeemed by %s, got token %q redeemed by %q", tt.userID, paper.UploadToken, paper.TokenRedeemedBy)
+			}
+		})
+	}
+}
+
+func TestSetGatewayIdentities(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		identities string
+		want       []string
+		wantErr    string
+	}{
+		{name: "sorted and deduplicated", role: "admin", identities: `["x509::CN=gw2","x509::CN=gw1","x509::CN=gw2"]`, want: []string{"x509::CN=gw1", "x509::CN=gw2"}},
+		{name: "cleared", role: "admin", identities: `[]`, want: nil},
+		{name: "empty identity", role: "admin", identities: `[""]`, wantErr: "must not contain empty values"},
+		{name: "invalid JSON", role: "admin", identities: `x509::CN=gw1`, wantErr: "invalid gateway identities"},
+		{name: "not admin", role: "coe", identities: `["x509::CN=gw1"]`, wantErr: "is not permitted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := env.as("user01", tt.role)
+			expectError(t, env.cc.SetGatewayIdentities(ctx, tt.identities), tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			config, err := getConfig(ctx)
+			if err != nil {
+				t.Fatalf("getConfig failed: %v", err)
+			}
+			if !reflect.DeepEqual(config.GatewayIdentities, tt.want) {
+				t.Fatalf("expected gateway identities %v, got %v", tt.want, config.GatewayIdentities)
+			}
+		})
+	}
+}
+
+// ===================== 试卷改名 =====================
+
+func TestRenamePaper(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		role    string
+		wantErr string
+	}{
+		{name: "teacher", role: "teacher"},
+		{name: "admin", role: "admin"},
+		{name: "student", role: "student", wantErr: "is not permitted"},
+		{name: "no role", role: "", wantErr: "is not permitted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			env.storePaper("P2", "EXAM1", unlock, "")
+			if err := env.cc.LinkRelatedPaper(env.as("teacher01", "teacher"), "P1", "P2", "makeup"); err != nil {
+				t.Fatalf("LinkRelatedPaper failed: %v", err)
+			}
+
+			err := env.cc.RenamePaper(env.as("user01", tt.role), "P1", "P9", "user01")
+			expectError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				env.getPaper("P1")
+				return
+			}
+
+			if _, ok := env.stub.State["P1"]; ok {
+				t.Fatalf("old paper record must be removed")
+			}
+			if related := env.getPaper("P9").RelatedPapers; !reflect.DeepEqual(related, []string{"P2"}) {
+				t.Fatalf("renamed paper related papers: %v", related)
+			}
+			if related := env.getPaper("P2").RelatedPapers; !reflect.DeepEqual(related, []string{"P9"}) {
+				t.Fatalf("related paper must point to the new ID, got %v", related)
+			}
+
+			ctx := env.as("teacher01", "teacher")
+			for _, want := range []PaperLink{{PaperID: "P9", RelatedID: "P2"}, {PaperID: "P2", RelatedID: "P9"}} {
+				links, err := env.cc.GetRelatedPapers(ctx, want.PaperID)
+				if err != nil {
+					t.Fatalf("GetRelatedPapers(%s) failed: %v", want.PaperID, err)
+				}
+				if len(links) != 1 || links[0].PaperID != want.PaperID || links[0].RelatedID != want.RelatedID {
+					got, _ := json.Marshal(links)
+					t.Fatalf("expected link %s -> %s, got %s", want.PaperID, want.RelatedID, got)
+				}
+			}
+		})
+	}
+}
+
+// ===================== 试卷修改 =====================
+
+func TestPatchPaper(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		patch    string
+		revision int
+		wantErr  string
+	}{
+		{name: "teacher", role: "teacher", patch: `{"subject":"物理"}`, revision: 1},
+		{name: "coe", role: "coe", patch: `{"subject":"物理"}`, revision: 1},
+		{name: "student", role: "student", patch: `{"subject":"物理"}`, revision: 1, wantErr: "is not permitted"},
+		{name: "no role", role: "", patch: `{"subject":"物理"}`, revision: 1, wantErr: "is not permitted"},
+		{name: "stale revision", role: "teacher", patch: `{"subject":"物理"}`, revision: 0, wantErr: "revision mismatch"},
+		{name: "field not patchable", role: "teacher", patch: `{"status":"unlocked"}`, revision: 1, wantErr: "cannot be patched"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", time.Now().Add(24*time.Hour), "")
+
+			err := env.cc.PatchPaper(env.as("user01", tt.role), "P1", tt.patch, tt.revision, "user01")
+			expectError(t, err, tt.wantErr)
+
+			want := "物理"
+			if tt.wantErr != "" {
+				want = "数学"
+			}
+			if subject := env.getPaper("P1").Subject; subject != want {
+				t.Fatalf("expected subject %s, got %s", want, subject)
+			}
+		})
+	}
+}
+
+// ===================== 副本管理 =====================
+
+func TestAddReplica(t *testing.T) {
+	replica := testIPFSHash("P1-replica")
+
+	tests := []struct {
+		name    string
+		role    string
+		cid     string
+		wantErr string
+	}{
+		{name: "teacher", role: "teacher", cid: replica},
+		{name: "admin", role: "admin", cid: replica},
+		{name: "student", role: "student", cid: replica, wantErr: "is not permitted"},
+		{name: "no role", role: "", cid: replica, wantErr: "is not permitted"},
+		{name: "primary hash", role: "teacher", cid: testIPFSHash("P1"), wantErr: "is the primary IPFS hash"},
+		{name: "gateway URL", role: "teacher", cid: "https://ipfs.io/ipfs/" + replica, wantErr: "store the bare CID only"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", time.Now().Add(24*time.Hour), "")
+
+			err := env.cc.AddReplica(env.as("user01", tt.role), "P1", tt.cid)
+			expectError(t, err, tt.wantErr)
+
+			var want []string
+			if tt.wantErr == "" {
+				want = []string{tt.cid}
+			}
+			if replicas := env.getPaper("P1").Replicas; !reflect.DeepEqual(replicas, want) {
+				t.Fatalf("expected replicas %v, got %v", want, replicas)
+			}
+		})
+	}
+}
+
+// ===================== 预留草稿 =====================
+
+func TestFinalizeDraft(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name     string
+		subject  string
+		finalize bool // 先完成一次，再次完成时试卷已不是草稿
+		wantErr  string
+	}{
+		{name: "keeps reserving uploader", subject: "数学"},
+		{name: "subject required", subject: "", wantErr: "subject is required"},
+		{name: "already finalized", subject: "数学", finalize: true, wantErr: "only draft papers can be finalized"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			if err := env.cc.ReservePaperID(env.as("teacher01", "teacher"), "P1", "EXAM1", "teacher01"); err != nil {
+				t.Fatalf("ReservePaperID failed: %v", err)
+			}
+			if tt.finalize {
+				err := env.cc.FinalizeDraft(env.as("teacher01", "teacher"), "P1", "数学", testIPFSHash("P1"), testFileHash("P1"), unlock, "teacher01")
+				if err != nil {
+					t.Fatalf("first FinalizeDraft failed: %v", err)
+				}
+			}
+
+			ctx := env.as("teacher02", "teacher")
+			err := env.cc.FinalizeDraft(ctx, "P1", tt.subject, testIPFSHash("P1"), testFileHash("P1"), unlock, "teacher02")
+			expectError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			paper := env.getPaper("P1")
+			if paper.Status != "locked" || paper.Subject != tt.subject {
+				t.Fatalf("expected locked paper with subject %s, got %s / %q", tt.subject, paper.Status, paper.Subject)
+			}
+			if paper.UploadedBy != "teacher01" || paper.ReservedBy != "teacher01" {
+				t.Fatalf("expected uploader teacher01 from the reservation, got %q (reserved by %q)", paper.UploadedBy, paper.ReservedBy)
+			}
+
+			logs, err := env.cc.GetPaperAccessLogs(ctx, "P1")
+			if err != nil {
+				t.Fatalf("GetPaperAccessLogs failed: %v", err)
+			}
+			found := false
+			for _, log := range logs {
+				if log.Action == "upload" && log.UserID == "teacher02" {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("expected an upload log by teacher02")
+			}
+		})
+	}
+}
+
+// ===================== 解锁条件 =====================
+
+// unlockGateCase 阻止解锁的一种前置条件，setup 在试卷 P1（考试 EXAM1）存储后构造该条件
+type unlockGateCase struct {
+	name       string
+	role       string // 发起解锁的调用者角色
+	callerGate bool   // 条件取决于调用者（角色、重试退避），调度器触发的解锁不检查
+	setup      func(env *testEnv, unlock time.Time)
+	wantReason string // 为空表示允许解锁
+}
+
+// unlockGateCases 返回 unlockBlockReason 检查的每一种前置条件，调用时的交易时间为解锁时间后一分钟
+func unlockGateCases() []unlockGateCase {
+	return []unlockGateCase{
+		{name: "unlockable", role: "superintendent"},
+		{name: "caller role", role: "teacher", callerGate: true, wantReason: "is not permitted"},
+		{
+			name: "retry backoff", role: "superintendent", callerGate: true,
+			setup: func(env *testEnv, unlock time.Time) {
+				if err := env.cc.SetUnlockRetryBackoff(env.as("admin01", "admin"), 300); err != nil {
+					env.t.Fatalf("SetUnlockRetryBackoff failed: %v", err)
+				}
+				env.setPaper("P1", func(paper *ExamPaper) {
+					paper.AttemptCount = 1
+					paper.LastUnlockAttempt = unlock.UTC().Format(time.RFC3339)
+				})
+			},
+			wantReason: "retry later",
+		},
+		{
+			name: "frozen", role: "superintendent",
+			setup: func(env *testEnv, unlock time.Time) {
+				if err := env.cc.Freeze(env.as("admin01", "admin"), "P1", "CASE-1"); err != nil {
+					env.t.Fatalf("Freeze failed: %v", err)
+				}
+			},
+			wantReason: "is frozen for investigation",
+		},
+		{
+			name: "on hold", role: "superintendent",
+			setup: func(env *testEnv, unlock time.Time) {
+				if err := env.cc.PlaceHold(env.as("admin01", "admin"), "P1", "investigation"); err != nil {
+					env.t.Fatalf("PlaceHold failed: %v", err)
+				}
+			},
+			wantReason: "is on hold: investigation",
+		},
+		{
+			name: "not locked", role: "superintendent",
+			setup: func(env *testEnv, unlock time.Time) {
+				env.setPaper("P1", func(paper *ExamPaper) { paper.Status = "draft" })
+			},
+			wantReason: "only locked papers can be unlocked",
+		},
+		{
+			name: "before unlock time", role: "superintendent",
+			setup: func(env *testEnv, unlock time.Time) {
+				env.setPaper("P1", func(paper *ExamPaper) { paper.UnlockTime = unlock.Add(time.Hour).UTC().Format(time.RFC3339) })
+			},
+			wantReason: "cannot be unlocked until",
+		},
+		{
+			name: "after exam end", role: "superintendent",
+			setup: func(env *testEnv, unlock time.Time) {
+				env.setPaper("P1", func(paper *ExamPaper) { paper.ExamEndTime = unlock.Add(30 * time.Second).UTC().Format(time.RFC3339) })
+			},
+			wantReason: "closed at",
+		},
+		{
+			name: "missing approvals", role: "superintendent",
+			setup: func(env *testEnv, unlock time.Time) {
+				env.setPaper("P1", func(paper *ExamPaper) { paper.RequiredApprovals = 1 })
+			},
+			wantReason: "requires 1 valid approvals, has 0",
+		},
+		{
+			name: "missing approver orgs", role: "superintendent",
+			setup: func(env *testEnv, unlock time.Time) {
+				env.setPaper("P1", func(paper *ExamPaper) { paper.RequiredApproverOrgs = 2 })
+			},
+			wantReason: "requires approvals from 2 distinct orgs",
+		},
+		{
+			name: "exam capacity reached", role: "superintendent",
+			setup: func(env *testEnv, unlock time.Time) {
+				fillUnlockCapacity(env, unlock)
+			},
+			wantReason: "exam EXAM1 unlock capacity reached, try later",
+		},
+	}
+}
+
+// fillUnlockCapacity 登记 EXAM1 并将并发解锁上限设为 1，再用已解锁的 P2 占满名额
+func fillUnlockCapacity(env *testEnv, unlock time.Time) {
+	env.t.Helper()
+	ctx := env.as("admin01", "admin")
+	if err := env.cc.RegisterExam(ctx, "EXAM1", "期末考试", "admin01"); err != nil {
+		env.t.Fatalf("RegisterExam failed: %v", err)
+	}
+	if err := env.cc.SetExamMaxConcurrentUnlocked(ctx, "EXAM1", 1); err != nil {
+		env.t.Fatalf("SetExamMaxConcurrentUnlocked failed: %v", err)
+	}
+	env.storePaper("P2", "EXAM1", unlock, "")
+	env.setPaper("P2", func(paper *ExamPaper) { paper.Status = "unlocked" })
+}
+
+func TestTriggerUnlockCheckSharesUnlockGates(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	for _, tt := range unlockGateCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			if tt.setup != nil {
+				tt.setup(env, unlock)
+			}
+			before := env.getPaper("P1").Status
+
+			ctx := env.as("scheduler", tt.role)
+			env.at(unlock.Add(time.Minute))
+			unlocked, err := env.cc.TriggerUnlockCheck(ctx, "P1")
+			if err != nil {
+				t.Fatalf("TriggerUnlockCheck failed: %v", err)
+			}
+
+			want := tt.wantReason == "" || tt.callerGate
+			if unlocked != want {
+				t.Fatalf("expected unlocked=%v, got %v", want, unlocked)
+			}
+			wantStatus := before
+			if want {
+				wantStatus = "unlocked"
+			}
+			if status := env.getPaper("P1").Status; status != wantStatus {
+				t.Fatalf("expected status %s, got %s", wantStatus, status)
+			}
+		})
+	}
+}
+
+// unlockMethods 解锁试卷的两个入口，后端通过 UpdatePaperStatus 解锁
+var unlockMethods = []struct {
+	name   string
+	unlock func(env *testEnv, ctx contractapi.TransactionContextInterface) error
+}{
+	{
+		name: "UnlockPaper",
+		unlock: func(env *testEnv, ctx contractapi.TransactionContextInterface) error {
+			return env.cc.UnlockPaper(ctx, "P1", "coe01")
+		},
+	},
+	{
+		name: "UpdatePaperStatus",
+		unlock: func(env *testEnv, ctx contractapi.TransactionContextInterface) error {
+			return env.cc.UpdatePaperStatus(ctx, "P1", "unlocked")
+		},
+	},
+}
+
+func TestHeldPaperCannotBeUnlocked(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	for _, m := range unlockMethods {
+		t.Run(m.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			if err := env.cc.PlaceHold(env.as("admin01", "admin"), "P1", "investigation"); err != nil {
+				t.Fatalf("PlaceHold failed: %v", err)
+			}
+
+			ctx := env.as("coe01", "coe")
+			env.at(unlock.Add(time.Minute))
+			expectError(t, m.unlock(env, ctx), "is on hold")
+			if status := env.getPaper("P1").Status; status != "locked" {
+				t.Fatalf("held paper must stay locked, got %s", status)
+			}
+
+			if err := env.cc.ReleaseHold(env.as("admin01", "admin"), "P1"); err != nil {
+				t.Fatalf("ReleaseHold failed: %v", err)
+			}
+			ctx = env.as("coe01", "coe")
+			env.at(unlock.Add(2 * time.Minute))
+			expectError(t, m.unlock(env, ctx), "")
+			if status := env.getPaper("P1").Status; status != "unlocked" {
+				t.Fatalf("expected paper unlocked after the hold is released, got %s", status)
+			}
+		})
+	}
+}
+
+func TestExamUnlockCapacity(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	// 各入口在名额已满时的结果统一转换为 error，TriggerUnlockCheck 不返回原因
+	methods := []struct {
+		name    string
+		unlock  func(env *testEnv, ctx contractapi.TransactionContextInterface) error
+		wantErr string
+	}{
+		{
+			name: "UnlockPaper",
+			unlock: func(env *testEnv, ctx contractapi.TransactionContextInterface) error {
+				return env.cc.UnlockPaper(ctx, "P1", "coe01")
+			},
+			wantErr: "exam EXAM1 unlock capacity reached, try later",
+		},
+		{
+			name: "UpdatePaperStatus",
+			unlock: func(env *testEnv, ctx contractapi.TransactionContextInterface) error {
+				return env.cc.UpdatePaperStatus(ctx, "P1", "unlocked")
+			},
+			wantErr: "exam EXAM1 unlock capacity reached, try later",
+		},
+		{
+			name: "AttemptUnlock",
+			unlock: func(env *testEnv, ctx contractapi.TransactionContextInterface) error {
+				check, err := env.cc.AttemptUnlock(ctx, "P1", "coe01")
+				if err != nil || check.Allowed {
+					return err
+				}
+				return fmt.Errorf("%s", check.Reason)
+			},
+			wantErr: "exam EXAM1 unlock capacity reached, try later",
+		},
+		{
+			name: "TriggerUnlockCheck",
+			unlock: func(env *testEnv, ctx contractapi.TransactionContextInterface) error {
+				unlocked, err := env.cc.TriggerUnlockCheck(ctx, "P1")
+				if err != nil || unlocked {
+					return err
+				}
+				return fmt.Errorf("paper not unlocked")
+			},
+			wantErr: "paper not unlocked",
+		},
+	}
+
+	for _, m := range methods {
+		t.Run(m.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			fillUnlockCapacity(env, unlock)
+
+			ctx := env.as("coe01", "coe")
+			env.at(unlock.Add(time.Minute))
+			expectError(t, m.unlock(env, ctx), m.wantErr)
+			if status := env.getPaper("P1").Status; status != "locked" {
+				t.Fatalf("paper must stay locked while the exam is at capacity, got %s", status)
+			}
+
+			// 归档已解锁的试卷释放名额
+			ctx = env.as("admin01", "admin")
+			env.at(unlock.Add(2 * time.Minute))
+			if err := env.cc.UpdatePaperStatus(ctx, "P2", "archived"); err != nil {
+				t.Fatalf("archiving P2 failed: %v", err)
+			}
+
+			// AttemptUnlock 失败后有重试退避，未配置退避间隔时不受影响
+			ctx = env.as("coe01", "coe")
+			env.at(unlock.Add(3 * time.Minute))
+			expectError(t, m.unlock(env, ctx), "")
+			if status := env.getPaper("P1").Status; status != "unlocked" {
+				t.Fatalf("expected paper unlocked once a slot is free, got %s", status)
+			}
+		})
+	}
+}
+
+func TestCanUnlockAgreesWithUnlockPaper(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	for _, tt := range unlockGateCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			if tt.setup != nil {
+				tt.setup(env, unlock)
+			}
+
+			ctx := env.as("user01", tt.role)
+			env.at(unlock.Add(time.Minute))
+			check, err := env.cc.CanUnlock(ctx, "P1")
+			if err != nil {
+				t.Fatalf("CanUnlock failed: %v", err)
+			}
+			if check.Allowed != (tt.wantReason == "") || !strings.Contains(check.Reason, tt.wantReason) {
+				t.Fatalf("expected reason containing %q, got allowed=%v reason %q", tt.wantReason, check.Allowed, check.Reason)
+			}
+
+			err = env.cc.UnlockPaper(ctx, "P1", "user01")
+			if check.Allowed {
+				expectError(t, err, "")
+				return
+			}
+			if err == nil || err.Error() != check.Reason {
+				t.Fatalf("CanUnlock reported %q but UnlockPaper returned %v", check.Reason, err)
+			}
+		})
+	}
+}
+
+// ===================== 解锁审批 =====================
+
+func TestFrozenPapersRejectApprovals(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		frozen  bool
+		wantErr string
+	}{
+		{name: "not frozen"},
+		{name: "frozen", frozen: true, wantErr: "is frozen for investigation (case CASE-1)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/ApproveUnlock", func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			if tt.frozen {
+				if err := env.cc.Freeze(env.as("admin01", "admin"), "P1", "CASE-1"); err != nil {
+					t.Fatalf("Freeze failed: %v", err)
+				}
+			}
+
+			err := env.cc.ApproveUnlock(env.as("coe01", "coe"), "P1", "coe01")
+			expectError(t, err, tt.wantErr)
+
+			approvals, err := env.cc.GetUnlockApprovals(env.as("admin01", "admin"), "P1")
+			if err != nil {
+				t.Fatalf("GetUnlockApprovals failed: %v", err)
+			}
+			if want := map[bool]int{false: 1, true: 0}[tt.frozen]; len(approvals) != want {
+				t.Fatalf("expected %d approvals, got %d", want, len(approvals))
+			}
+		})
+
+		t.Run(tt.name+"/ApproveReleaseBatch", func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", unlock, "")
+			if tt.frozen {
+				if err := env.cc.Freeze(env.as("admin01", "admin"), "P1", "CASE-1"); err != nil {
+					t.Fatalf("Freeze failed: %v", err)
+				}
+			}
+
+			results, err := env.cc.ApproveReleaseBatch(env.as("admin01", "admin"), `["P1"]`, "admin01")
+			if err != nil {
+				t.Fatalf("ApproveReleaseBatch failed: %v", err)
+			}
+			if len(results) != 1 || results[0].Approved == tt.frozen || !strings.Contains(results[0].Reason, tt.wantErr) {
+				got, _ := json.Marshal(results)
+				t.Fatalf("unexpected results %s", got)
+			}
+			if approved := env.getPaper("P1").ReleaseApproved; approved == tt.frozen {
+				t.Fatalf("expected release approved %v, got %v", !tt.frozen, approved)
+			}
+		})
+	}
+}
+
+// ===================== 列表查询隐去 =====================
+
+// paperListMethods 返回试卷列表的查询方法，均应包含存储在 EXAM1 下、由 teacher01 上传的 P1
+var paperListMethods = []struct {
+	name string
+	list func(env *testEnv, ctx contractapi.TransactionContextInterface) ([]*ExamPaper, error)
+}{
+	{"GetPapersByExam", func(env *testEnv, ctx contractapi.TransactionContextInterface) ([]*ExamPaper, error) {
+		return env.cc.GetPapersByExam(ctx, "EXAM1")
+	}},
+	{"GetPapersByExams", func(env *testEnv, ctx contractapi.TransactionContextInterface) ([]*ExamPaper, error) {
+		page, err := env.cc.GetPapersByExams(ctx, `["EXAM1"]`, 10, "")
+		if err != nil {
+			return nil, err
+		}
+		return page.Papers, nil
+	}},
+	{"GetAllPapers", func(env *testEnv, ctx contractapi.TransactionContextInterface) ([]*ExamPaper, error) {
+		page, err := env.cc.GetAllPapers(ctx, 10, "")
+		if err != nil {
+			return nil, err
+		}
+		return page.Papers, nil
+	}},
+	{"GetPapersByStatus", func(env *testEnv, ctx contractapi.TransactionContextInterface) ([]*ExamPaper, error) {
+		return env.cc.GetPapersByStatus(ctx, "locked")
+	}},
+	{"GetPapersByUploader", func(env *testEnv, ctx contractapi.TransactionContextInterface) ([]*ExamPaper, error) {
+		return env.cc.GetPapersByUploader(ctx, "teacher01")
+	}},
+	{"GetPapersByDepartment", func(env *testEnv, ctx contractapi.TransactionContextInterface) ([]*ExamPaper, error) {
+		return env.cc.GetPapersByDepartment(ctx, "计算机系")
+	}},
+	{"GetPapersByFaculty", func(env *testEnv, ctx contractapi.TransactionContextInterface) ([]*ExamPaper, error) {
+		return env.cc.GetPapersByFaculty(ctx, "信息学院")
+	}},
+	{"GetPapersByOrg", func(env *testEnv, ctx contractapi.TransactionContextInterface) ([]*ExamPaper, error) {
+		return env.cc.GetPapersByOrg(ctx, "Org1MSP")
+	}},
+	{"GetUnverifiedPapers", func(env *testEnv, ctx contractapi.TransactionContextInterface) ([]*ExamPaper, error) {
+		return env.cc.GetUnverifiedPapers(ctx, "EXAM1")
+	}},
+}
+
+// listedPaper 从列表结果中取出 P1
+func listedPaper(t *testing.T, papers []*ExamPaper) *ExamPaper {
+	t.Helper()
+	for _, paper := range papers {
+		if paper.PaperID == "P1" {
+			return paper
+		}
+	}
+	t.Fatalf("paper P1 missing from %d results", len(papers))
+	return nil
+}
+
+func TestListQueriesHonourAllowedViewers(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour)
+	replicas := []string{testIPFSHash("replica")}
+
+	tests := []struct {
+		name      string
+		userID    string
+		role      string
+		wantShown bool
+	}{
+		{name: "listed viewer", userID: "student02", role: "student", wantShown: true},
+		{name: "unlisted user", userID: "student01", role: "student"},
+		{name: "unlisted teacher", userID: "teacher02", role: "teacher"},
+		{name: "admin", userID: "admin02", role: "admin", wantShown: true},
+	}
+
+	for _, m := range paperListMethods {
+		for _, tt := range tests {
+			t.Run(m.name+"/"+tt.name, func(t *testing.T) {
+				env := newTestEnv(t)
+				env.storePaper("P1", "EXAM1", unlock, `{"department":"计算机系","faculty":"信息学院"}`)
+				env.setPaper("P1", func(paper *ExamPaper) {
+					paper.Replicas = replicas
+					paper.AllowedViewers = []string{"student02"}
+				})
+
+				papers, err := m.list(env, env.as(tt.userID, tt.role))
+				if err != nil {
+					t.Fatalf("%s failed: %v", m.name, err)
+				}
+				paper := listedPaper(t, papers)
+
+				wantIPFS, wantFile, wantReplicas := "", "", []string(nil)
+				if tt.wantShown {
+					wantIPFS, wantFile, wantReplicas = testIPFSHash("P1"), testFileHash("P1"), replicas
+				}
+				if paper.IPFSHash != wantIPFS || paper.FileHash != wantFile || !reflect.DeepEqual(paper.Replicas, wantReplicas) {
+					t.Fatalf("expected ipfs %q file %q replicas %v, got %q %q %v",
+						wantIPFS, wantFile, wantReplicas, paper.IPFSHash, paper.FileHash, paper.Replicas)
+				}
+				if stored := env.getPaper("P1"); stored.IPFSHash != testIPFSHash("P1") || len(stored.AllowedViewers) != 1 {
+					t.Fatalf("redaction must not change the stored paper")
+				}
+			})
+		}
+	}
+}
+
+func TestListQueriesSealAnswerKeyCommitment(t *testing.T) {
+	unlock := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		role      string
+		at        time.Time
+		wantShown bool
+	}{
+		{name: "student before release", role: "student", at: unlock.Add(-time.Minute)},
+		{name: "teacher before release", role: "teacher", at: unlock.Add(-time.Minute)},
+		{name: "student after release", role: "student", at: unlock.Add(time.Minute), wantShown: true},
+		{name: "admin before release", role: "admin", at: unlock.Add(-time.Minute), wantShown: true},
+	}
+
+	for _, m := range paperListMethods {
+		for _, tt := range tests {
+			t.Run(m.name+"/"+tt.name, func(t *testing.T) {
+				env := newTestEnv(t)
+				env.storePaper("P1", "EXAM1", unlock, `{"department":"计算机系","faculty":"信息学院"}`)
+				if err := env.cc.StoreAnswerKey(env.as("teacher01", "teacher"), "P1", "commitment-1", "teacher01"); err != nil {
+					t.Fatalf("StoreAnswerKey failed: %v", err)
+				}
+
+				ctx := env.as("user01", tt.role)
+				env.at(tt.at)
+				papers, err := m.list(env, ctx)
+				if err != nil {
+					t.Fatalf("%s failed: %v", m.name, err)
+				}
+
+				want := ""
+				if tt.wantShown {
+					want = "commitment-1"
+				}
+				if got := listedPaper(t, papers).AnswerKeyCommitment; got != want {
+					t.Fatalf("expected answer key commitment %q, got %q", want, got)
+				}
+			})
+		}
+	}
+}
+
+// ===================== 日志分页 =====================
+
+func TestGetPaperAccessLogsPaginatedHasMore(t *testing.T) {
+	tests := []struct {
+		name        string
+		logs        int
+		pageSize    int32
+		wantHasMore []bool
+	}{
+		{name: "last page exactly full", logs: 4, pageSize: 2, wantHasMore: []bool{true, false}},
+		{name: "last page partial", logs: 5, pageSize: 2, wantHasMore: []bool{true, true, false}},
+		{name: "single full page", logs: 3, pageSize: 3, wantHasMore: []bool{false}},
+		{name: "single partial page", logs: 3, pageSize: 5, wantHasMore: []bool{false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.storePaper("P1", "EXAM1", time.Now().Add(24*time.Hour), "")
+			existing, err := env.cc.GetPaperAccessLogs(env.as("admin01", "admin"), "P1")
+			if err != nil {
+				t.Fatalf("GetPaperAccessLogs failed: %v", err)
+			}
+			for i := len(existing); i < tt.logs; i++ {
+				if err := env.cc.RecordAccess(env.as("teacher01", "teacher"), "P1", "teacher01", "view", "", ""); err != nil {
+					t.Fatalf("RecordAccess failed: %v", err)
+				}
+			}
+
+			ctx := env.as("admin01", "admin")
+			seen := map[string]bool{}
+			bookmark := ""
+			var gotHasMore []bool
+			for len(gotHasMore) <= len(tt.wantHasMore) {
+				page, err := env.cc.GetPaperAccessLogsPaginated(ctx, "P1", tt.pageSize, bookmark)
+				if err != nil {
+					t.Fatalf("GetPaperAccessLogsPaginated failed: %v", err)
+				}
+				for _, log := range page.Logs {
+					seen[log.LogID] = true
+				}
+				gotHasMore = append(gotHasMore, page.HasMore)
+				if !page.HasMore {
+					break
+				}
+				bookmark = page.Bookmark
+			}
+
+			if !reflect.DeepEqual(gotHasMore, tt.wantHasMore) {
+				t.Fatalf("expected HasMore sequence %v, got %v", tt.wantHasMore, gotHasMore)
+			}
+			if len(seen) != tt.logs {
+				t.Fatalf("expected %d distinct logs across pages, got %d", tt.logs, len(seen))
+			}
+		})
+	}
+}